@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/3lvia/hashivault-go/pkg/hashivault"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"syscall"
+	"time"
+)
+
+// stringsFlag is a flag that can be given multiple times.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return fmt.Sprint(*s)
+}
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+func runExec(ctx context.Context, args []string) error {
+	fs := newFlagSet("exec", "[flags] --secret <path> [--secret <path>...] -- <command> [args...]")
+	var vf vaultFlags
+	vf.register(fs)
+	var paths stringsFlag
+	fs.Var(&paths, "secret", "path of a secret to inject, may be repeated; later secrets take precedence")
+	prefix := fs.String("prefix", "", "prefix for the names of the injected variables")
+	uppercase := fs.Bool("uppercase", true, "normalise the names of the injected variables to upper case")
+	grace := fs.Duration("grace", 10*time.Second, "how long to wait for the command to exit before killing it")
+
+	var command []string
+	for i, a := range args {
+		if a == "--" {
+			args, command = args[:i], args[i+1:]
+			break
+		}
+	}
+
+	positional, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return usageError(fs, "the command must be given after --")
+	}
+	if len(paths) == 0 {
+		return usageError(fs, "at least one secret is required")
+	}
+	if len(command) == 0 {
+		return usageError(fs, "no command given")
+	}
+
+	sm, err := vf.manager(ctx)
+	if err != nil {
+		return err
+	}
+	defer sm.Close()
+
+	changed := make(chan struct{}, 1)
+	secrets := make([]hashivault.EvergreenSecretsFunc, 0, len(paths))
+	for _, p := range paths {
+		s, err := sm.Secret(ctx, p)
+		if err != nil {
+			return err
+		}
+		s.Notify(changed)
+		secrets = append(secrets, func() map[string]any {
+			// Get only fails when ctx is done, in which case the command is being stopped anyway
+			data, _, _ := s.Get(ctx)
+			return data
+		})
+	}
+
+	r := &runner{
+		command: command,
+		mapping: hashivault.EnvMapping{Prefix: *prefix, Uppercase: *uppercase},
+		secrets: secrets,
+		changed: changed,
+		grace:   *grace,
+		stdin:   os.Stdin,
+		stdout:  os.Stdout,
+		stderr:  os.Stderr,
+	}
+	return r.run(ctx)
+}
+
+// runner runs a command with secrets in its environment, and restarts it whenever the secrets change.
+type runner struct {
+	command []string
+	mapping hashivault.EnvMapping
+	secrets []hashivault.EvergreenSecretsFunc
+
+	// changed is signalled when the data of one of the secrets changes.
+	changed <-chan struct{}
+	grace   time.Duration
+
+	stdin          io.Reader
+	stdout, stderr io.Writer
+}
+
+// child is a running command.
+type child struct {
+	cmd  *exec.Cmd
+	done chan error
+}
+
+func (r *runner) run(ctx context.Context) error {
+	env, err := r.environ()
+	if err != nil {
+		return err
+	}
+
+	c, err := r.start(env)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case err := <-c.done:
+			return exitStatus(err)
+		case <-ctx.Done():
+			return exitStatus(r.stop(c))
+		case <-r.changed:
+			next, err := r.environ()
+			if err != nil {
+				fmt.Fprintf(r.stderr, "hashivault: %s\n", err)
+				continue
+			}
+			// a signal may represent several changes, which may have cancelled each other out
+			if slices.Equal(env, next) {
+				continue
+			}
+
+			fmt.Fprintln(r.stderr, "hashivault: secrets rotated, restarting command")
+			r.stop(c)
+			env = next
+			if c, err = r.start(env); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// environ returns the variables to inject, in the order of the secrets.
+func (r *runner) environ() ([]string, error) {
+	var env []string
+	for _, s := range r.secrets {
+		e, err := r.mapping.Environ(s())
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, e...)
+	}
+	return env, nil
+}
+
+func (r *runner) start(env []string) (*child, error) {
+	cmd := exec.Command(r.command[0], r.command[1:]...)
+	// Later entries take precedence, so the secrets override variables of the same name in the current environment.
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = r.stdin
+	cmd.Stdout = r.stdout
+	cmd.Stderr = r.stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	c := &child{cmd: cmd, done: make(chan error, 1)}
+	go func() {
+		c.done <- cmd.Wait()
+	}()
+	return c, nil
+}
+
+// stop asks the command to terminate, and kills it if it has not exited within the grace period.
+func (r *runner) stop(c *child) error {
+	if err := c.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		_ = c.cmd.Process.Kill()
+	}
+
+	select {
+	case err := <-c.done:
+		return err
+	case <-time.After(r.grace):
+		_ = c.cmd.Process.Kill()
+		return <-c.done
+	}
+}
+
+// exitStatus converts the result of a finished command to the error returned by the exec command.
+func exitStatus(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if code := exitErr.ExitCode(); code > 0 {
+			return &exitError{code: code}
+		}
+		// terminated by a signal
+		return &exitError{code: 1}
+	}
+	return err
+}
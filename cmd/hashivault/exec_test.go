@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"github.com/3lvia/hashivault-go/internal/testing/assert"
+	"github.com/3lvia/hashivault-go/pkg/hashivault"
+	"io"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_runExec_usage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "no secret", args: []string{"--", "env"}},
+		{name: "no command", args: []string{"-secret", "kunde/kv/data/db"}},
+		{name: "empty command", args: []string{"-secret", "kunde/kv/data/db", "--"}},
+		{name: "command without --", args: []string{"-secret", "kunde/kv/data/db", "env"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := runExec(context.Background(), tt.args); !errors.Is(err, errUsage) {
+				t.Errorf("expected a usage error, got: %v", err)
+			}
+		})
+	}
+}
+
+func Test_runner_restart(t *testing.T) {
+	var mux sync.Mutex
+	value := "first"
+	secret := func() map[string]any {
+		mux.Lock()
+		defer mux.Unlock()
+		return map[string]any{"value": value}
+	}
+
+	pr, pw := io.Pipe()
+	// the channel is unbuffered, so that a send returns once the runner has handled the previous signal
+	changed := make(chan struct{})
+	r := &runner{
+		command: []string{"sh", "-c", `echo "$VALUE"; exec sleep 10`},
+		mapping: hashivault.EnvMapping{Uppercase: true},
+		secrets: []hashivault.EvergreenSecretsFunc{secret},
+		changed: changed,
+		grace:   time.Second,
+		stdout:  pw,
+		stderr:  io.Discard,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.run(ctx)
+	}()
+
+	lines := bufio.NewScanner(pr)
+	expectLine := func(want string) {
+		t.Helper()
+		if !lines.Scan() || lines.Text() != want {
+			t.Fatalf("expected the command to print %q, got %q", want, lines.Text())
+		}
+	}
+	expectLine("first")
+
+	// a signal without a change does not restart the command, or it would print "first" again
+	changed <- struct{}{}
+	changed <- struct{}{}
+
+	mux.Lock()
+	value = "second"
+	mux.Unlock()
+	changed <- struct{}{}
+	expectLine("second")
+
+	cancel()
+	var exitErr *exitError
+	if err := <-done; !errors.As(err, &exitErr) || exitErr.code != 1 {
+		t.Errorf("expected the exit status of a terminated command, got: %v", err)
+	}
+}
+
+func Test_runner_exitStatus(t *testing.T) {
+	r := &runner{
+		command: []string{"sh", "-c", "exit 3"},
+		secrets: []hashivault.EvergreenSecretsFunc{func() map[string]any { return nil }},
+		stdout:  io.Discard,
+		stderr:  io.Discard,
+	}
+
+	err := r.run(context.Background())
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) || exitErr.code != 3 {
+		t.Errorf("expected exit status 3, got: %v", err)
+	}
+}
+
+func Test_exitStatus(t *testing.T) {
+	assert.NoErr(t, exitStatus(nil))
+
+	err := errors.New("not started")
+	if got := exitStatus(err); got != err {
+		t.Errorf("expected other errors to be returned as is, got: %v", got)
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(exitStatus(err), &exitErr) {
+		t.Error("unexpected exit error")
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/3lvia/hashivault-go/pkg/hashivault"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"strings"
+)
+
+func runGet(ctx context.Context, args []string) error {
+	fs := newFlagSet("get", "[flags] <path>")
+	var vf vaultFlags
+	vf.register(fs)
+	field := fs.String("field", "", "print only the value of the given key, verbatim")
+	format := fs.String("format", "json", "output format: json, yaml or env")
+	prefix := fs.String("prefix", "", "prefix for variable names when using the env format")
+
+	positional, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageError(fs, "expected exactly one path")
+	}
+
+	sm, err := vf.manager(ctx)
+	if err != nil {
+		return err
+	}
+	defer sm.Close()
+
+	s, err := sm.GetSecret(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+
+	secrets := s()
+	if *field != "" {
+		v, ok := secrets[*field]
+		if !ok {
+			return fmt.Errorf("key %s not found in secret", *field)
+		}
+		return writeValue(os.Stdout, v)
+	}
+
+	return writeSecrets(os.Stdout, secrets, *format, *prefix)
+}
+
+// writeValue writes a single value. Strings are written verbatim, other values as JSON.
+func writeValue(w io.Writer, v any) error {
+	if s, ok := v.(string); ok {
+		_, err := fmt.Fprintln(w, s)
+		return err
+	}
+	return json.NewEncoder(w).Encode(v)
+}
+
+// writeSecrets writes all the keys and values of a secret in the given format.
+func writeSecrets(w io.Writer, secrets map[string]any, format, prefix string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(secrets)
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(secrets); err != nil {
+			return err
+		}
+		return enc.Close()
+	case "env":
+		env, err := hashivault.EnvMapping{Prefix: prefix, Uppercase: true}.Environ(secrets)
+		if err != nil {
+			return err
+		}
+		for _, kv := range env {
+			name, value, _ := strings.Cut(kv, "=")
+			if _, err := fmt.Fprintf(w, "%s=%s\n", name, quoteEnv(value)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown format %q, expected json, yaml or env", format)
+}
+
+// quoteEnv single-quotes values that would otherwise be misinterpreted when the output is sourced by a shell.
+func quoteEnv(v string) string {
+	if !strings.ContainsAny(v, " \t\r\n\"'`$\\#;&|<>(){}*?[]~!") {
+		return v
+	}
+	return "'" + strings.ReplaceAll(v, "'", `'\''`) + "'"
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"github.com/3lvia/hashivault-go/internal/testing/assert"
+	"testing"
+)
+
+func Test_quoteEnv(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "plain", want: "plain"},
+		{value: "", want: ""},
+		{value: "with space", want: "'with space'"},
+		{value: "$HOME", want: "'$HOME'"},
+		{value: "it's", want: `'it'\''s'`},
+		{value: "a;rm -rf /", want: "'a;rm -rf /'"},
+		{value: "line\nbreak", want: "'line\nbreak'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := quoteEnv(tt.value); got != tt.want {
+				t.Errorf("quoteEnv(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_writeSecrets(t *testing.T) {
+	secrets := map[string]any{
+		"client-id": "my-client-id",
+		"password":  "p@ss word",
+		"port":      float64(8080),
+	}
+	tests := []struct {
+		format  string
+		prefix  string
+		want    string
+		wantErr string
+	}{
+		{
+			format: "json",
+			want:   "{\n  \"client-id\": \"my-client-id\",\n  \"password\": \"p@ss word\",\n  \"port\": 8080\n}\n",
+		},
+		{
+			format: "yaml",
+			want:   "client-id: my-client-id\npassword: p@ss word\nport: 8080\n",
+		},
+		{
+			format: "env",
+			prefix: "app_",
+			want:   "APP_CLIENT_ID=my-client-id\nAPP_PASSWORD='p@ss word'\nAPP_PORT=8080\n",
+		},
+		{
+			format:  "xml",
+			wantErr: `unknown format "xml"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := writeSecrets(&buf, secrets, tt.format, tt.prefix)
+			if tt.wantErr != "" {
+				assert.Err(t, err, tt.wantErr)
+				return
+			}
+			assert.NoErr(t, err)
+			if got := buf.String(); got != tt.want {
+				t.Errorf("writeSecrets() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_writeValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{name: "string verbatim", value: "s3cr3t \"quoted\"", want: "s3cr3t \"quoted\"\n"},
+		{name: "number", value: float64(42), want: "42\n"},
+		{name: "object", value: map[string]any{"a": true}, want: "{\"a\":true}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.NoErr(t, writeValue(&buf, tt.value))
+			if got := buf.String(); got != tt.want {
+				t.Errorf("writeValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
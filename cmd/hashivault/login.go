@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/3lvia/hashivault-go/pkg/hashivault"
+)
+
+func runLogin(ctx context.Context, args []string) error {
+	fs := newFlagSet("login", "[flags]")
+	var vf vaultFlags
+	vf.register(fs)
+
+	positional, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return usageError(fs, "unexpected arguments")
+	}
+
+	token, err := hashivault.Login(ctx, vf.options()...)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(token)
+	return nil
+}
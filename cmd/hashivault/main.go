@@ -0,0 +1,197 @@
+// Command hashivault is a small command line client for Vault built on the hashivault package. Since it uses the same
+// options, environment variables and authentication precedence as the package, it behaves exactly like the services
+// that use the package.
+//
+// Usage:
+//
+//	hashivault get [flags] <path>
+//	hashivault exec [flags] --secret <path> [--secret <path>...] -- <command> [args...]
+//	hashivault render [flags] -template <file> -out <file>
+//	hashivault login [flags]
+//
+// Run "hashivault <command> -h" for the flags of each command.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/3lvia/hashivault-go/pkg/hashivault"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const usage = `hashivault is a command line client for Vault.
+
+Usage:
+
+	hashivault <command> [flags] [arguments]
+
+The commands are:
+
+	get     print a secret
+	exec    run a command with secrets in its environment
+	render  render a template with secrets to a file
+	login   authenticate and print a Vault token
+
+Vault is configured with the same environment variables as the hashivault package, i.e. VAULT_ADDR, VAULT_TOKEN,
+VAULT_TOKEN_FILE, VAULT_WRAPPED_TOKEN, VAULT_WRAPPED_TOKEN_FILE, GITHUB_TOKEN, MOUNT_PATH and ROLE. If none of them
+selects an authentication method, the token in ~/.vault-token is used. Set VAULT_OIDC_HEADLESS=true to log in with
+OIDC on a remote machine. With -ldap or -userpass, the password is read from VAULT_PASSWORD or the terminal.
+`
+
+// errUsage signals that the command line arguments are invalid, and that the usage has already been printed.
+var errUsage = errors.New("invalid usage")
+
+type command func(ctx context.Context, args []string) error
+
+var commands = map[string]command{
+	"get":    runGet,
+	"exec":   runExec,
+	"render": runRender,
+	"login":  runLogin,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		if os.Args[1] != "-h" && os.Args[1] != "--help" && os.Args[1] != "help" {
+			fmt.Fprintf(os.Stderr, "hashivault: unknown command %q\n\n", os.Args[1])
+		}
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := cmd(ctx, os.Args[2:])
+	stop()
+
+	var exitErr *exitError
+	switch {
+	case err == nil:
+	case errors.Is(err, errUsage), errors.Is(err, flag.ErrHelp):
+		os.Exit(2)
+	case errors.As(err, &exitErr):
+		os.Exit(exitErr.code)
+	default:
+		fmt.Fprintf(os.Stderr, "hashivault: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// exitError is returned by commands that want the process to exit with a specific code without printing anything.
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// vaultFlags holds the flags that configure the connection to Vault, common to all commands.
+type vaultFlags struct {
+	address string
+	oidc    bool
+	oidcCfg hashivault.OIDCConfig
+	oidcHL  bool
+	ldap    string
+	userp   string
+	verbose bool
+}
+
+func (v *vaultFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&v.address, "address", "", "address of the Vault server, VAULT_ADDR takes precedence")
+	fs.BoolVar(&v.oidc, "oidc", false, "authenticate using OIDC unless other credentials are found in the environment")
+	fs.StringVar(&v.oidcCfg.Role, "oidc-role", "", "OIDC role to log in with, implies -oidc")
+	fs.StringVar(&v.oidcCfg.Mount, "oidc-mount", "", "path where the OIDC auth method is mounted, implies -oidc")
+	fs.StringVar(&v.oidcCfg.Port, "oidc-port", "", "port of the OIDC callback server, implies -oidc")
+	fs.BoolVar(&v.oidcCfg.SkipBrowser, "oidc-skip-browser", false, "print the OIDC login URL instead of opening the browser, implies -oidc")
+	fs.BoolVar(&v.oidcHL, "oidc-headless", false, "log in with OIDC by pasting the callback URL, for remote machines, implies -oidc")
+	fs.StringVar(&v.ldap, "ldap", "", "log in with LDAP as the given user, the password is read from VAULT_PASSWORD or the terminal")
+	fs.StringVar(&v.userp, "userpass", "", "log in with userpass as the given user, the password is read from VAULT_PASSWORD or the terminal")
+	fs.BoolVar(&v.verbose, "v", false, "log to stderr")
+}
+
+func (v *vaultFlags) options() []hashivault.Option {
+	var opts []hashivault.Option
+	if v.address != "" {
+		opts = append(opts, hashivault.WithVaultAddress(v.address))
+	}
+	if v.oidcCfg != (hashivault.OIDCConfig{}) {
+		opts = append(opts, hashivault.WithOIDCConfig(v.oidcCfg))
+	} else if v.oidc {
+		opts = append(opts, hashivault.WithOIDC())
+	}
+	if v.oidcHL {
+		opts = append(opts, hashivault.WithOIDCHeadless())
+	}
+	cfg := hashivault.PasswordConfig{PasswordPrompt: hashivault.PromptPassword}
+	if v.ldap != "" {
+		cfg.Username = v.ldap
+		opts = append(opts, hashivault.WithLDAP(cfg))
+	} else if v.userp != "" {
+		cfg.Username = v.userp
+		opts = append(opts, hashivault.WithUserpass(cfg))
+	}
+	opts = append(opts, hashivault.WithMFAProvider(hashivault.PromptPasscode))
+	if v.verbose {
+		opts = append(opts, hashivault.WithLogger(log.New(os.Stderr, "", log.LstdFlags)))
+	}
+	return opts
+}
+
+// manager creates a new secrets manager. Errors from the background jobs are written to stderr.
+func (v *vaultFlags) manager(ctx context.Context) (hashivault.SecretsManager, error) {
+	opts := append(v.options(), hashivault.WithErrorHandler(func(e hashivault.Event) {
+		fmt.Fprintf(os.Stderr, "hashivault: %s\n", e)
+	}))
+	sm, _, err := hashivault.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return sm, nil
+}
+
+// parse parses the flags of a command. Unlike flag.FlagSet.Parse, flags may be given after the positional arguments,
+// e.g. "get <path> -field key". Everything after "--" is returned verbatim.
+func parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		if len(args) > len(rest) && args[len(args)-len(rest)-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+func newFlagSet(name, synopsis string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: hashivault %s %s\n\nFlags:\n", name, synopsis)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func usageError(fs *flag.FlagSet, format string, a ...any) error {
+	fmt.Fprintf(fs.Output(), "hashivault %s: %s\n\n", fs.Name(), fmt.Sprintf(format, a...))
+	fs.Usage()
+	return errUsage
+}
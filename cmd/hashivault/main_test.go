@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"github.com/3lvia/hashivault-go/internal/testing/assert"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func Test_parse(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           []string
+		wantPositional []string
+		wantField      string
+		wantVerbose    bool
+		wantErr        bool
+	}{
+		{
+			name:           "flags before path",
+			args:           []string{"-field", "password", "kunde/kv/data/db"},
+			wantPositional: []string{"kunde/kv/data/db"},
+			wantField:      "password",
+		},
+		{
+			name:           "flags after path",
+			args:           []string{"kunde/kv/data/db", "-field", "password", "-v"},
+			wantPositional: []string{"kunde/kv/data/db"},
+			wantField:      "password",
+			wantVerbose:    true,
+		},
+		{
+			name:           "flags between paths",
+			args:           []string{"a", "-v", "b"},
+			wantPositional: []string{"a", "b"},
+			wantVerbose:    true,
+		},
+		{
+			name:           "everything after -- is positional",
+			args:           []string{"-v", "--", "-field", "x"},
+			wantPositional: []string{"-field", "x"},
+			wantVerbose:    true,
+		},
+		{
+			name:    "unknown flag",
+			args:    []string{"-unknown"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			field := fs.String("field", "", "")
+			verbose := fs.Bool("v", false, "")
+
+			got, err := parse(fs, tt.args)
+			if tt.wantErr {
+				assert.Err(t, err, "flag provided but not defined")
+				return
+			}
+			assert.NoErr(t, err)
+			if !reflect.DeepEqual(got, tt.wantPositional) {
+				t.Errorf("parse() = %v, want %v", got, tt.wantPositional)
+			}
+			if *field != tt.wantField || *verbose != tt.wantVerbose {
+				t.Errorf("unexpected flags, got field %q and verbose %v", *field, *verbose)
+			}
+		})
+	}
+}
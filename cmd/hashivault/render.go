@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"github.com/3lvia/hashivault-go/pkg/hashivault"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// signals are the signals that can be sent to reload a process.
+var signals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"TERM": syscall.SIGTERM,
+	"QUIT": syscall.SIGQUIT,
+}
+
+func runRender(ctx context.Context, args []string) error {
+	fs := newFlagSet("render", "[flags] -template <file> -out <file> [-- <reload command> [args...]]")
+	var vf vaultFlags
+	vf.register(fs)
+	tmplPath := fs.String("template", "", "path of the template, - for stdin")
+	outPath := fs.String("out", "", "path of the rendered file")
+	perms := fs.String("perms", "0600", "permissions of the rendered file, in octal")
+	watch := fs.Bool("watch", false, "keep running and re-render the file when the secrets change")
+	pid := fs.Int("pid", 0, "process to signal when the file has been re-rendered, requires -watch")
+	sig := fs.String("signal", "HUP", "signal to send to the process given by -pid")
+
+	var reloadCmd []string
+	for i, a := range args {
+		if a == "--" {
+			args, reloadCmd = args[:i], args[i+1:]
+			break
+		}
+	}
+
+	positional, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return usageError(fs, "unexpected arguments")
+	}
+	if *tmplPath == "" || *outPath == "" {
+		return usageError(fs, "both -template and -out are required")
+	}
+	mode, err := strconv.ParseUint(*perms, 8, 32)
+	if err != nil {
+		return usageError(fs, "invalid permissions %q", *perms)
+	}
+
+	var opts []hashivault.RenderOption
+	if len(reloadCmd) > 0 {
+		opts = append(opts, hashivault.WithReloadCommand(reloadCmd[0], reloadCmd[1:]...))
+	}
+	if *pid != 0 {
+		s, ok := signals[strings.TrimPrefix(strings.ToUpper(*sig), "SIG")]
+		if !ok {
+			return usageError(fs, "unsupported signal %q", *sig)
+		}
+		opts = append(opts, hashivault.WithReloadSignal(*pid, s))
+	}
+	if len(opts) > 0 && !*watch {
+		return usageError(fs, "a reload command or signal requires -watch")
+	}
+
+	var text []byte
+	if *tmplPath == "-" {
+		text, err = io.ReadAll(os.Stdin)
+	} else {
+		text, err = os.ReadFile(*tmplPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	sm, err := vf.manager(ctx)
+	if err != nil {
+		return err
+	}
+	defer sm.Close()
+
+	if err := sm.Render(ctx, string(text), *outPath, os.FileMode(mode), opts...); err != nil {
+		return err
+	}
+
+	if *watch {
+		<-ctx.Done()
+	}
+	return nil
+}
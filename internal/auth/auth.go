@@ -6,11 +6,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/3lvia/hashivault-go/internal/logging"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 	"net/http"
-	"os"
 )
 
 const defaultTracerName = "go.opentelemetry.io/otel"
@@ -18,60 +18,131 @@ const defaultTracerName = "go.opentelemetry.io/otel"
 var tracerName string
 
 func Authenticate(ctx context.Context, addr string, method Method, opts ...Option) (AuthenticationResponse, error) {
-	collector := &optionsCollector{}
-	for _, opt := range opts {
-		opt(collector)
+	collector := newOptionsCollector(opts)
+
+	l := collector.l.With(logging.Component("auth"), logging.Method(methodToString(method)))
+	l.Printf("authenticating to %s using %s", addr, methodToString(method))
+
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(
+		ctx,
+		"auth.Authenticate",
+		trace.WithAttributes(attribute.String("method", methodToString(method))))
+	defer span.End()
+
+	client := collector.client
+	if client == nil {
+		client = &http.Client{}
 	}
 
-	tracerName = collector.otelTracerName
-	if tracerName == "" {
-		tracerName = defaultTracerName
+	a, err := collector.authenticator(method)
+	if err != nil {
+		traceError(span, err)
+		return nil, err
 	}
 
-	l := collector.l
-	l.Printf("authenticating to %s using %s", addr, methodToString(method))
+	r, err := a.Login(spanCtx, &Client{Address: addr, HTTPClient: client, MFAProvider: collector.mfa})
+	if err != nil {
+		l.With(logging.Error(err)).Errorf("error authenticating using %s: %s", methodToString(method), err)
+		traceError(span, err)
+		return nil, err
+	}
+
+	l.With(logging.LeaseDuration(r.LeaseDuration)).
+		Printf("successfully authenticated using %s, got client token of length %d", methodToString(method), len(r.Token))
+	return result{r}, nil
+}
+
+// Renew returns a new token when the given token is about to expire. If the authenticator of the method is a
+// Renewer, the token is renewed, otherwise a new token is obtained by logging in again.
+func Renew(ctx context.Context, addr string, method Method, token string, opts ...Option) (AuthenticationResponse, error) {
+	collector := newOptionsCollector(opts)
 
 	tracer := otel.GetTracerProvider().Tracer(tracerName)
 	spanCtx, span := tracer.Start(
 		ctx,
-		"auth.Authenticate",
+		"auth.Renew",
 		trace.WithAttributes(attribute.String("method", methodToString(method))))
 	defer span.End()
 
+	a, err := collector.authenticator(method)
+	if err != nil {
+		traceError(span, err)
+		return nil, err
+	}
+
+	renewer, ok := a.(Renewer)
+	if !ok {
+		return Authenticate(spanCtx, addr, method, opts...)
+	}
+
 	client := collector.client
 	if client == nil {
 		client = &http.Client{}
 	}
 
+	r, err := renewer.Renew(spanCtx, &Client{Address: addr, HTTPClient: client, MFAProvider: collector.mfa}, token)
+	if err != nil {
+		traceError(span, err)
+		return nil, err
+	}
+
+	collector.l.With(logging.Component("auth"), logging.Method(methodToString(method)), logging.LeaseDuration(r.LeaseDuration)).
+		Printf("renewed token, new lease duration %s", r.LeaseDuration)
+	return result{r}, nil
+}
+
+// authenticator returns the authenticator for the method, configured by the options.
+func (c *optionsCollector) authenticator(method Method) (Authenticator, error) {
 	switch method {
-	case MethodOICD:
-		return authOICD(spanCtx, addr)
+	case MethodCustom:
+		if c.custom == nil {
+			return nil, errors.New("no authenticator provided")
+		}
+		return c.custom, nil
+	case MethodWrappedToken:
+		if c.wrappedToken == "" {
+			return nil, errors.New("no wrapped token provided")
+		}
+		return wrappedTokenAuthenticator{token: c.wrappedToken}, nil
+	case MethodOICD, MethodOICDHeadless:
+		return oidcAuthenticator{cfg: c.oidcConfig, headless: method == MethodOICDHeadless, cache: c.oidcCache, l: c.l}, nil
+	case MethodUserpass, MethodLDAP:
+		return passwordAuthenticator{method: method, cfg: c.password}, nil
 	case MethodGitHub:
-		if collector.gitHubToken == "" {
-			err := errors.New("no GitHub token provided")
-			traceError(span, err)
-			return nil, err
+		if c.gitHubToken == "" {
+			return nil, errors.New("no GitHub token provided")
 		}
-		return authGitHub(spanCtx, addr, collector.gitHubToken, client)
+		return gitHubAuthenticator{token: c.gitHubToken}, nil
 	case MethodK8s:
-		if collector.k8sServicePath == "" || collector.k8sRole == "" {
-			err := errors.New("no k8s service path or role provided")
-			traceError(span, err)
-			return nil, err
+		if c.k8s.Mount == "" || c.k8s.Role == "" {
+			return nil, errors.New("no k8s service path or role provided")
 		}
-		l.Printf("using k8s service path %s and role %s", collector.k8sServicePath, collector.k8sRole)
-		r, err := authK8s(spanCtx, addr, collector.k8sServicePath, collector.k8sRole, client)
-		if err != nil {
-			l.Printf("error authenticating to k8s: %s", err)
-		} else {
-			l.Printf("successfully authenticated to k8s, got client token of length %d", len(r.ClientToken()))
-		}
-		return r, err
+		c.l.With(logging.Component("auth"), logging.Method(methodToString(method)), logging.Path(c.k8s.Mount)).
+			Printf("using k8s service path %s and role %s", c.k8s.Mount, c.k8s.Role)
+		return k8sAuthenticator{cfg: c.k8s}, nil
+	}
+
+	return nil, fmt.Errorf("unknown authentication method: %s", methodToString(method))
+}
+
+// newOptionsCollector applies the options, and sets the package tracer name and a noop logger if none is given.
+func newOptionsCollector(opts []Option) *optionsCollector {
+	collector := &optionsCollector{}
+	for _, opt := range opts {
+		opt(collector)
+	}
+
+	tracerName = collector.otelTracerName
+	if tracerName == "" {
+		tracerName = defaultTracerName
+	}
+
+	if collector.l == nil {
+		collector.l = logging.New(nil, nil)
 	}
 
-	err := fmt.Errorf("unknown authentication method: %s", methodToString(method))
-	traceError(span, err)
-	return nil, err
+	return collector
 }
 
 // authReq returns a http request for authenticating to Vault
@@ -101,22 +172,6 @@ func loginBuffer(lt interface{}) (*bytes.Buffer, error) {
 	return bytes.NewBuffer(js), nil
 }
 
-// getJWT reads JSON web token from file at the service path
-func getJWT(k8ServicePath string) (string, error) {
-	b, err := os.ReadFile(k8ServicePath)
-	if err == nil {
-		//return "", fmt.Errorf("failed to read jwt token from %s: %w", k8ServicePath, err)
-		return string(bytes.TrimSpace(b)), nil
-	}
-
-	b, err = os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/token")
-	if err != nil {
-		return "", fmt.Errorf("failed to read jwt token from hard-coded path: %w", err)
-	}
-
-	return string(bytes.TrimSpace(b)), nil
-}
-
 func traceError(span trace.Span, err error) {
 	if err != nil {
 		span.RecordError(err)
@@ -2,10 +2,19 @@ package auth
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"github.com/3lvia/hashivault-go/internal/logging"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestAuthenticate_github(t *testing.T) {
@@ -28,21 +37,554 @@ func TestAuthenticate_github(t *testing.T) {
 
 func TestAuthenticate_k8s(t *testing.T) {
 	ctx := context.Background()
+	var gotPath, gotJWT string
 	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body k8sToken
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		gotPath, gotJWT = r.URL.Path, body.JWT
 		fmt.Fprintln(w, ghVaultResponse)
 	}))
 	defer testServer.Close()
 
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	jwt := testJWT(t, `{"aud":["vault"],"sub":"system:serviceaccount:default:app"}`)
+	if err := os.WriteFile(tokenPath, []byte(jwt+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
 	servicePath := "MY_SERVICE_PATH"
 	role := "MY_ROLE"
-	tokenResponse, err := Authenticate(ctx, testServer.URL, MethodK8s, WithK8s(servicePath, role), WithClient(testServer.Client()))
+	cfg := K8sConfig{Mount: servicePath, Role: role, TokenPath: tokenPath}
+	tokenResponse, err := Authenticate(ctx, testServer.URL, MethodK8s, WithK8sConfig(cfg), WithClient(testServer.Client()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if tokenResponse.ClientToken() != "xxx" {
+		t.Errorf("unexpected token: %s", tokenResponse.ClientToken())
+	}
+	if gotPath != "/v1/auth/MY_SERVICE_PATH/login" || gotJWT != jwt {
+		t.Errorf("unexpected login request: %s %s", gotPath, gotJWT)
+	}
+
+	// projected tokens are rotated by the kubelet, so the token is read on each login
+	rotated := testJWT(t, `{"aud":"vault"}`)
+	if err := os.WriteFile(tokenPath, []byte(rotated), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Mount = "auth/k8s-prod/"
+	cfg.Audience = "vault"
+	if _, err := Authenticate(ctx, testServer.URL, MethodK8s, WithK8sConfig(cfg), WithClient(testServer.Client())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotPath != "/v1/auth/k8s-prod/login" || gotJWT != rotated {
+		t.Errorf("unexpected login request: %s %s", gotPath, gotJWT)
+	}
+
+	cfg.Audience = "other"
+	_, err = Authenticate(ctx, testServer.URL, MethodK8s, WithK8sConfig(cfg), WithClient(testServer.Client()))
+	if err == nil || !strings.Contains(err.Error(), "not other") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	cfg.TokenPath = filepath.Join(t.TempDir(), "missing")
+	if _, err := Authenticate(ctx, testServer.URL, MethodK8s, WithK8sConfig(cfg), WithClient(testServer.Client())); err == nil {
+		t.Error("expected error when the token file is missing")
+	}
+}
+
+// testJWT returns an unsigned JSON web token with the claims.
+func testJWT(t *testing.T, claims string) string {
+	t.Helper()
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"none"}`)) + "." + enc.EncodeToString([]byte(claims)) + ".sig"
+}
+
+func TestAuthenticate_wrappedToken(t *testing.T) {
+	ctx := context.Background()
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/sys/wrapping/unwrap" || r.Header.Get("X-Vault-Token") != "MY_WRAPPED_TOKEN" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w, ghVaultResponse)
+	}))
+	defer testServer.Close()
+
+	tokenResponse, err := Authenticate(ctx, testServer.URL, MethodWrappedToken, WithWrappedToken("MY_WRAPPED_TOKEN"), WithClient(testServer.Client()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if tokenResponse.ClientToken() != "xxx" {
+		t.Errorf("unexpected token: %s", tokenResponse.ClientToken())
+	}
+
+	_, err = Authenticate(ctx, testServer.URL, MethodWrappedToken, WithWrappedToken("USED_WRAPPED_TOKEN"), WithClient(testServer.Client()))
+	if err == nil {
+		t.Error("expected error for invalid wrapped token")
+	}
+}
+
+// userpassAuthenticator is a custom authenticator, as users of the package would implement it.
+type userpassAuthenticator struct {
+	user, password string
+}
+
+func (a userpassAuthenticator) Login(ctx context.Context, c *Client) (AuthResult, error) {
+	return c.Login(ctx, "auth/userpass/login/"+a.user, map[string]string{"password": a.password})
+}
+
+func (a userpassAuthenticator) Renew(ctx context.Context, c *Client, token string) (AuthResult, error) {
+	return c.RenewSelf(ctx, token)
+}
+
+func TestAuthenticate_custom(t *testing.T) {
+	ctx := context.Background()
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/auth/userpass/login/jane":
+			var body map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["password"] != "secret" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintln(w, `{"errors":["invalid username or password"]}`)
+				return
+			}
+			fmt.Fprintln(w, ghVaultResponse)
+		case r.URL.Path == "/v1/auth/token/renew-self" && r.Header.Get("X-Vault-Token") == "xxx":
+			fmt.Fprintln(w, ghVaultResponse)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer testServer.Close()
+
+	a := userpassAuthenticator{user: "jane", password: "secret"}
+	tokenResponse, err := Authenticate(ctx, testServer.URL, MethodCustom, WithAuthenticator(a), WithClient(testServer.Client()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tokenResponse.ClientToken() != "xxx" || tokenResponse.LeaseDurationSeconds() != 2764800 || !tokenResponse.Renewable() {
+		t.Errorf("unexpected token: %+v", tokenResponse)
+	}
+
+	tokenResponse, err = Renew(ctx, testServer.URL, MethodCustom, "xxx", WithAuthenticator(a), WithClient(testServer.Client()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tokenResponse.ClientToken() != "xxx" {
+		t.Errorf("unexpected token: %s", tokenResponse.ClientToken())
+	}
+
+	a.password = "wrong"
+	_, err = Authenticate(ctx, testServer.URL, MethodCustom, WithAuthenticator(a), WithClient(testServer.Client()))
+	if err == nil || !strings.Contains(err.Error(), "invalid username or password") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestAuthenticate_password(t *testing.T) {
+	ctx := context.Background()
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/ldap/login/jane":
+			var body map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["password"] != "secret" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintln(w, `{"errors":["ldap operation failed"]}`)
+				return
+			}
+			fmt.Fprintln(w, `{"auth":{"mfa_requirement":{"mfa_request_id":"req-1","mfa_constraints":{"totp":{"any":[{"type":"totp","id":"method-1","uses_passcode":true}]}}}}}`)
+		case "/v1/sys/mfa/validate":
+			var body mfaValidation
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.RequestID != "req-1" || body.Payload["method-1"][0] != "123456" {
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprintln(w, `{"errors":["failed to validate MFA"]}`)
+				return
+			}
+			fmt.Fprintln(w, ghVaultResponse)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	cfg := PasswordConfig{
+		Username:       "jane",
+		PasswordPrompt: func(username string) (string, error) { return "secret", nil },
+	}
+	provider := func(m MFAMethod) (string, error) {
+		if m.Type != "totp" || m.Name != "totp" {
+			t.Errorf("unexpected MFA method: %+v", m)
+		}
+		return "123456", nil
+	}
+	tokenResponse, err := Authenticate(ctx, testServer.URL, MethodLDAP, WithPassword(cfg), WithMFAProvider(provider), WithClient(testServer.Client()))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	if tokenResponse.ClientToken() != "xxx" {
+		t.Errorf("unexpected token: %s", tokenResponse.ClientToken())
+	}
+
+	_, err = Authenticate(ctx, testServer.URL, MethodLDAP, WithPassword(cfg), WithClient(testServer.Client()))
+	if err == nil || !strings.Contains(err.Error(), "requires a passcode") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	cfg.Password = "wrong"
+	_, err = Authenticate(ctx, testServer.URL, MethodLDAP, WithPassword(cfg), WithMFAProvider(provider), WithClient(testServer.Client()))
+	if err == nil || !strings.Contains(err.Error(), "ldap operation failed") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRenew_password(t *testing.T) {
+	tests := []struct {
+		name        string
+		lookup      string
+		granted     int
+		wantPrompts int
+	}{
+		{name: "renewed", lookup: `{"data":{"ttl":1200,"creation_ttl":3600,"renewable":true}}`, granted: 3600},
+		{name: "max ttl", lookup: `{"data":{"ttl":1200,"creation_ttl":3600,"renewable":true}}`, granted: 600, wantPrompts: 1},
+		{name: "not renewable", lookup: `{"data":{"ttl":1200,"creation_ttl":3600,"renewable":false}}`, wantPrompts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v1/auth/token/lookup-self":
+					fmt.Fprintln(w, tt.lookup)
+				case "/v1/auth/token/renew-self":
+					fmt.Fprintf(w, `{"auth":{"client_token":"xxx","lease_duration":%d,"renewable":true}}`, tt.granted)
+				case "/v1/auth/userpass/login/jane":
+					fmt.Fprintln(w, ghVaultResponse)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer testServer.Close()
 
+			prompts := 0
+			cfg := PasswordConfig{
+				Username: "jane",
+				PasswordPrompt: func(username string) (string, error) {
+					prompts++
+					return "secret", nil
+				},
+			}
+			tokenResponse, err := Renew(context.Background(), testServer.URL, MethodUserpass, "xxx", WithPassword(cfg), WithClient(testServer.Client()))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tokenResponse.ClientToken() != "xxx" {
+				t.Errorf("unexpected token: %s", tokenResponse.ClientToken())
+			}
+			if prompts != tt.wantPrompts {
+				t.Errorf("unexpected number of password prompts: %d", prompts)
+			}
+		})
+	}
+}
+
+func TestAuthenticate_mfa(t *testing.T) {
+	ctx := context.Background()
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/userpass/login/jane":
+			fmt.Fprintln(w, `{"auth":{"client_token":"","mfa_requirement":{"mfa_request_id":"req-1","mfa_constraints":{"duo":{"any":[{"type":"duo","id":"method-1","uses_passcode":false}]}}}}}`)
+		case "/v1/sys/mfa/validate":
+			var body mfaValidation
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.RequestID != "req-1" || len(body.Payload["method-1"]) != 1 || body.Payload["method-1"][0] != "" {
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprintln(w, `{"errors":["failed to validate MFA"]}`)
+				return
+			}
+			fmt.Fprintln(w, ghVaultResponse)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	var pushed []string
+	provider := func(m MFAMethod) (string, error) {
+		pushed = append(pushed, m.Type)
+		return "ignored", nil
+	}
+
+	a := userpassAuthenticator{user: "jane", password: "secret"}
+	tokenResponse, err := Authenticate(ctx, testServer.URL, MethodCustom, WithAuthenticator(a), WithMFAProvider(provider), WithClient(testServer.Client()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 	if tokenResponse.ClientToken() != "xxx" {
 		t.Errorf("unexpected token: %s", tokenResponse.ClientToken())
 	}
+	if len(pushed) != 1 || pushed[0] != "duo" {
+		t.Errorf("unexpected calls to MFA provider: %v", pushed)
+	}
+}
+
+func TestOIDCConfig_fields(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  OIDCConfig
+		want map[string]string
+	}{
+		{
+			name: "defaults",
+			want: map[string]string{},
+		},
+		{
+			name: "role and port",
+			cfg:  OIDCConfig{Role: "developer", Mount: "azure", Port: "8251", SkipBrowser: true},
+			want: map[string]string{"role": "developer", "mount": "azure", FieldPort: "8251", FieldSkipBrowser: "true"},
+		},
+		{
+			name: "auth url handler",
+			cfg:  OIDCConfig{CallbackHost: "devbox", AuthURLHandler: func(string) {}},
+			want: map[string]string{FieldCallbackHost: "devbox", FieldSkipBrowser: "true"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.fields(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticate_oidc(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/oidc/oidc/auth_url":
+			fmt.Fprintln(w, `{"data":{"auth_url":"https://login.example.com/authorize"}}`)
+		case "/v1/auth/oidc/oidc/callback":
+			if r.URL.Query().Get("code") != "my-code" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintln(w, ghVaultResponse)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	port := freePort(t)
+	cfg := OIDCConfig{
+		Port:    port,
+		Timeout: 5 * time.Second,
+		AuthURLHandler: func(authURL string) {
+			// act as the browser being redirected back after the login
+			go http.Get("http://localhost:" + port + "/oidc/callback?state=my-state&code=my-code")
+		},
+	}
+
+	// logging in twice in the same process must neither panic nor fail because the port is still in use
+	for i := 0; i < 2; i++ {
+		tokenResponse, err := Authenticate(context.Background(), testServer.URL, MethodOICD, WithOIDCConfig(cfg))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if tokenResponse.ClientToken() != "xxx" {
+			t.Errorf("unexpected token: %s", tokenResponse.ClientToken())
+		}
+	}
+
+	// the login times out if the callback is never called
+	cfg.Timeout = 100 * time.Millisecond
+	cfg.AuthURLHandler = func(string) {}
+	if _, err := Authenticate(context.Background(), testServer.URL, MethodOICD, WithOIDCConfig(cfg)); err == nil {
+		t.Error("expected error when the login is not completed")
+	}
+}
+
+func TestAuthenticate_oidcHeadless(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/oidc/oidc/auth_url":
+			fmt.Fprintln(w, `{"data":{"auth_url":"https://login.example.com/authorize"}}`)
+		case "/v1/auth/oidc/oidc/callback":
+			if r.URL.Query().Get("state") != "my-state" || r.URL.Query().Get("code") != "my-code" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintln(w, ghVaultResponse)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	in := strings.NewReader("not a url\nhttp://localhost:8250/oidc/callback?state=my-state&code=my-code\n")
+	var out strings.Builder
+	tokenResponse, err := authOICDHeadless(context.Background(), &Client{Address: testServer.URL}, OIDCConfig{}, in, &out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tokenResponse.Token != "xxx" {
+		t.Errorf("unexpected token: %s", tokenResponse.Token)
+	}
+	if !strings.Contains(out.String(), "https://login.example.com/authorize") || !strings.Contains(out.String(), "try again") {
+		t.Errorf("unexpected output: %s", out.String())
+	}
+
+	if _, err := authOICDHeadless(context.Background(), &Client{Address: testServer.URL}, OIDCConfig{}, strings.NewReader(""), &out); err == nil {
+		t.Error("expected error when no callback URL is given")
+	}
+}
+
+// freePort returns a port on localhost that is not in use.
+func freePort(t *testing.T) string {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer l.Close()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	return port
+}
+
+func TestOIDCCache(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    bool
+		status int
+		wantOK bool
+	}{
+		{name: "valid token", status: http.StatusOK, wantOK: true},
+		{name: "valid encrypted token", key: true, status: http.StatusOK, wantOK: true},
+		{name: "revoked token", status: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+			if tt.key {
+				if err := os.WriteFile(filepath.Join(home, ".hashivault-key"), []byte("my-key"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/auth/token/lookup-self" || r.Header.Get("X-Vault-Token") != "xxx" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				w.WriteHeader(tt.status)
+				fmt.Fprintln(w, `{"data":{"ttl":3000,"renewable":true}}`)
+			}))
+			defer testServer.Close()
+
+			l := logging.New(nil, nil)
+			cache, err := newOIDCCache(testServer.URL, OIDCConfig{}, l)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := cache.save(AuthResult{Token: "xxx", LeaseDuration: time.Hour}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			info, err := os.Stat(cache.path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Mode().Perm() != 0600 {
+				t.Errorf("unexpected file mode: %s", info.Mode())
+			}
+			b, _ := os.ReadFile(cache.path)
+			if tt.key == strings.Contains(string(b), "xxx") {
+				t.Errorf("unexpected cache file content: %s", b)
+			}
+
+			r, ok := cache.load(context.Background(), &Client{Address: testServer.URL, HTTPClient: testServer.Client()})
+			if ok != tt.wantOK {
+				t.Fatalf("unexpected result from load: %v", ok)
+			}
+			if ok && (r.Token != "xxx" || r.LeaseDuration != 3000*time.Second || !r.Renewable) {
+				t.Errorf("unexpected cached token: %+v", r)
+			}
+		})
+	}
+}
+
+func TestRenew_oidc(t *testing.T) {
+	tests := []struct {
+		name       string
+		granted    int
+		wantLogins int
+	}{
+		{name: "renewed", granted: 3600},
+		{name: "max ttl", granted: 600, wantLogins: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+
+			logins := 0
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v1/auth/token/lookup-self":
+					fmt.Fprintln(w, `{"data":{"ttl":1200,"creation_ttl":3600,"renewable":true}}`)
+				case "/v1/auth/token/renew-self":
+					fmt.Fprintf(w, `{"auth":{"client_token":"xxx","lease_duration":%d,"renewable":true}}`, tt.granted)
+				case "/v1/auth/oidc/oidc/auth_url":
+					logins++
+					fmt.Fprintln(w, `{"data":{"auth_url":"https://login.example.com/authorize"}}`)
+				case "/v1/auth/oidc/oidc/callback":
+					fmt.Fprintln(w, ghVaultResponse)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer testServer.Close()
+
+			port := freePort(t)
+			cfg := OIDCConfig{
+				Port:    port,
+				Timeout: 5 * time.Second,
+				AuthURLHandler: func(authURL string) {
+					go http.Get("http://localhost:" + port + "/oidc/callback?state=my-state&code=my-code")
+				},
+			}
+
+			tokenResponse, err := Renew(context.Background(), testServer.URL, MethodOICD, "xxx",
+				WithOIDCConfig(cfg), WithOIDCCache(true), WithClient(testServer.Client()))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if logins != tt.wantLogins {
+				t.Errorf("unexpected number of logins: %d", logins)
+			}
+
+			cache, err := newOIDCCache(testServer.URL, cfg, logging.New(nil, nil))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			b, err := os.ReadFile(cache.path)
+			if err != nil {
+				t.Fatalf("expected the token to be cached: %v", err)
+			}
+			var cached cachedToken
+			if err := json.Unmarshal(b, &cached); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			want := time.Now().Add(time.Duration(tokenResponse.LeaseDurationSeconds()) * time.Second)
+			if cached.ClientToken != "xxx" || cached.Expires.Sub(want).Abs() > time.Minute {
+				t.Errorf("unexpected cached token, expires %s, want %s", cached.Expires, want)
+			}
+		})
+	}
 }
 
 const ghVaultResponse = `{
@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Authenticator logs in to Vault. Implementations are used for the built-in authentication methods, and can be given
+// with WithAuthenticator to log in with any other auth method, such as userpass or LDAP.
+type Authenticator interface {
+	// Login authenticates to Vault and returns the resulting token.
+	Login(ctx context.Context, c *Client) (AuthResult, error)
+}
+
+// Renewer is implemented by authenticators that can renew the token when it is about to expire. Tokens from
+// authenticators that are not renewers are replaced by logging in again.
+type Renewer interface {
+	// Renew renews the given token, and returns it with its new lease duration.
+	Renew(ctx context.Context, c *Client, token string) (AuthResult, error)
+}
+
+// AuthResult is the token obtained by an Authenticator.
+type AuthResult struct {
+	// Token is the Vault token.
+	Token string
+
+	// LeaseDuration is how long the token is valid, zero if it does not expire.
+	LeaseDuration time.Duration
+
+	// Renewable is true if the token can be renewed.
+	Renewable bool
+}
+
+// Client is given to authenticators to make requests to Vault.
+type Client struct {
+	// Address is the address of the Vault server.
+	Address string
+
+	// HTTPClient is the client to use for requests to Vault.
+	HTTPClient *http.Client
+
+	// MFAProvider completes the MFA of logins that require it.
+	MFAProvider MFAProvider
+}
+
+// Login writes data to the login endpoint at path, e.g. "auth/userpass/login/jane", and returns the token in the
+// response. If the login requires MFA, it is completed with the MFA provider before the token is returned.
+func (c *Client) Login(ctx context.Context, path string, data any) (AuthResult, error) {
+	response, err := c.login(ctx, path, data)
+	if err != nil {
+		return AuthResult{}, err
+	}
+	if response.Auth.MFARequirement != nil {
+		if response, err = c.validateMFA(ctx, response.Auth.MFARequirement); err != nil {
+			return AuthResult{}, err
+		}
+	}
+	if response.Auth.ClientToken == "" {
+		return AuthResult{}, fmt.Errorf("no token in response from %s", path)
+	}
+
+	return response.result(), nil
+}
+
+// login writes data to the login endpoint at path, and returns the response.
+func (c *Client) login(ctx context.Context, path string, data any) (authenticationResponse, error) {
+	body, err := loginBuffer(data)
+	if err != nil {
+		return authenticationResponse{}, err
+	}
+
+	req, err := authReq(c.Address, path, body)
+	if err != nil {
+		return authenticationResponse{}, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	return c.do(req)
+}
+
+// RenewSelf renews the given token, and returns it with its new lease duration.
+func (c *Client) RenewSelf(ctx context.Context, token string) (AuthResult, error) {
+	response, err := c.tokenRequest(ctx, "auth/token/renew-self", token)
+	if err != nil {
+		return AuthResult{}, fmt.Errorf("while renewing token: %w", err)
+	}
+	return response.result(), nil
+}
+
+// TokenInfo is a token as looked up in Vault. CreationTTL is the TTL the token was created with, which Vault grants
+// when the token is renewed until it reaches its max TTL.
+type TokenInfo struct {
+	AuthResult
+	CreationTTL time.Duration
+}
+
+// LookupSelf looks up the token in Vault, and returns it with its remaining lifetime. An error is returned if the
+// token is not valid.
+func LookupSelf(ctx context.Context, c *Client, token string) (TokenInfo, error) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	_, span := tracer.Start(ctx, "auth.LookupSelf", trace.WithAttributes(attribute.String("vault_addr", c.Address)))
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, makeURL(c.Address, "auth/token/lookup-self"), nil)
+	if err != nil {
+		return TokenInfo{}, fmt.Errorf("while building http request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", token)
+
+	// The lookup response has the token information in data rather than in auth.
+	response, err := c.do(req)
+	if err != nil {
+		traceError(span, err)
+		return TokenInfo{}, err
+	}
+	data, _ := response.Data.(map[string]interface{})
+	ttl, _ := data["ttl"].(float64)
+	creationTTL, _ := data["creation_ttl"].(float64)
+	renewable, _ := data["renewable"].(bool)
+
+	return TokenInfo{
+		AuthResult: AuthResult{
+			Token:         token,
+			LeaseDuration: time.Duration(ttl) * time.Second,
+			Renewable:     renewable,
+		},
+		CreationTTL: time.Duration(creationTTL) * time.Second,
+	}, nil
+}
+
+// renewToken renews the token with renew-self. It returns false if the token cannot be renewed any further, because it
+// is no longer valid, is not renewable or has reached its max TTL, in which case a new token must be obtained by logging
+// in again. Other errors are returned, so that the renewal is retried while the token is still valid.
+func (c *Client) renewToken(ctx context.Context, token string) (AuthResult, bool, error) {
+	info, err := LookupSelf(ctx, c, token)
+	var se *statusError
+	if errors.As(err, &se) && se.code == http.StatusForbidden {
+		return AuthResult{}, false, nil
+	}
+	if err != nil {
+		return AuthResult{}, false, err
+	}
+	if !info.Renewable {
+		return AuthResult{}, false, nil
+	}
+
+	r, err := c.RenewSelf(ctx, token)
+	if err != nil {
+		return AuthResult{}, false, err
+	}
+	if !r.Renewable || r.LeaseDuration < info.CreationTTL {
+		return r, false, nil
+	}
+	return r, true, nil
+}
+
+// tokenRequest sends an empty POST request authenticated with the given token, and parses the authentication data
+// in the response.
+func (c *Client) tokenRequest(ctx context.Context, path, token string) (authenticationResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, makeURL(c.Address, path), nil)
+	if err != nil {
+		return authenticationResponse{}, fmt.Errorf("while building http request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", token)
+
+	return c.do(req)
+}
+
+func (c *Client) do(req *http.Request) (authenticationResponse, error) {
+	client := c.HTTPClient
+	if client == nil {
+		client = &http.Client{}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return authenticationResponse{}, fmt.Errorf("while sending http request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return authenticationResponse{}, fmt.Errorf("while reading response body: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return authenticationResponse{}, &statusError{code: resp.StatusCode, errors: vaultErrors(body)}
+	}
+
+	var response authenticationResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return authenticationResponse{}, fmt.Errorf("while unmarshalling response body: %w", err)
+	}
+
+	return response, nil
+}
+
+// statusError is returned when Vault responds with an unexpected status code, e.g. 403 for a token that is no longer
+// valid.
+type statusError struct {
+	code   int
+	errors string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d%s", e.code, e.errors)
+}
+
+// vaultErrors returns the errors in a Vault error response formatted for appending to an error message, or the
+// empty string if there are none.
+func vaultErrors(body []byte) string {
+	var resp struct {
+		Errors []string `json:"errors"`
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(body), &resp); err != nil || len(resp.Errors) == 0 {
+		return ""
+	}
+	return ": " + strings.Join(resp.Errors, "; ")
+}
+
+// result is an AuthResult in the form of an AuthenticationResponse.
+type result struct {
+	r AuthResult
+}
+
+func (r result) ClientToken() string {
+	return r.r.Token
+}
+
+func (r result) LeaseDurationSeconds() int {
+	return int(r.r.LeaseDuration / time.Second)
+}
+
+func (r result) Renewable() bool {
+	return r.r.Renewable
+}
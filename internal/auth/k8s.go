@@ -3,67 +3,135 @@ package auth
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
-	"io"
-	"net/http"
+	"os"
+	"strings"
 )
 
-func authK8s(ctx context.Context, vaultAddr, k8ServicePath, role string, client *http.Client) (AuthenticationResponse, error) {
+// defaultK8sTokenPath is where Kubernetes mounts the service account token of the pod.
+const defaultK8sTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// K8sConfig configures Kubernetes authentication.
+type K8sConfig struct {
+	// Mount is the path where the Kubernetes auth method is mounted, e.g. "kubernetes" or "auth/k8s-prod".
+	Mount string
+
+	// Role is the Vault role to log in with.
+	Role string
+
+	// TokenPath is the file with the service account token, the token mounted by Kubernetes by default. Projected
+	// tokens, which are bound to the pod and rotated by the kubelet, are mounted at a path given in the pod spec.
+	TokenPath string
+
+	// Audience is the audience the token must be issued for, if set. The audience of projected tokens is given in the
+	// pod spec, and must match the audience of the Vault role.
+	Audience string
+}
+
+// k8sAuthenticator logs in with a service account token of the pod. The token is read on each login, as projected
+// tokens are rotated.
+type k8sAuthenticator struct {
+	cfg K8sConfig
+}
+
+func (a k8sAuthenticator) Login(ctx context.Context, c *Client) (AuthResult, error) {
+	tokenPath := a.cfg.TokenPath
+	if tokenPath == "" {
+		tokenPath = defaultK8sTokenPath
+	}
+	mount := strings.TrimPrefix(strings.Trim(a.cfg.Mount, "/"), "auth/")
+
 	tracer := otel.GetTracerProvider().Tracer(tracerName)
-	_, span := tracer.Start(
+	spanCtx, span := tracer.Start(
 		ctx,
-		"auth.authGitHub",
+		"auth.authK8s",
 		trace.WithAttributes(
-			attribute.String("vault_addr", vaultAddr),
-			attribute.String("k8s_service_path", k8ServicePath),
-			attribute.String("k8s_role", role),
+			attribute.String("vault_addr", c.Address),
+			attribute.String("k8s_service_path", mount),
+			attribute.String("k8s_role", a.cfg.Role),
+			attribute.String("k8s_token_path", tokenPath),
 		))
 	defer span.End()
 
-	path := "auth/" + k8ServicePath + "/login"
-	requestBody, err := k8sLogin(k8ServicePath, role)
+	jwt, err := getJWT(tokenPath)
 	if err != nil {
-		return nil, err
+		traceError(span, err)
+		return AuthResult{}, err
 	}
-
-	req, err := authReq(vaultAddr, path, requestBody)
-	if err != nil {
-		return nil, fmt.Errorf("while building http request: %w", err)
+	if a.cfg.Audience != "" {
+		if err := checkAudience(jwt, a.cfg.Audience); err != nil {
+			err = fmt.Errorf("service account token in %s: %w", tokenPath, err)
+			traceError(span, err)
+			return AuthResult{}, err
+		}
 	}
 
-	resp, err := client.Do(req)
+	r, err := c.Login(spanCtx, "auth/"+mount+"/login", &k8sToken{
+		JWT:  jwt,
+		Role: a.cfg.Role,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("while sending http request: %w", err)
+		traceError(span, err)
+		return AuthResult{}, err
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("while reading response body: %w", err)
-	}
-	defer resp.Body.Close()
+	return r, nil
+}
 
-	var response authenticationResponse
-	err = json.Unmarshal(body, &response)
+// getJWT reads the JSON web token from the file at tokenPath
+func getJWT(tokenPath string) (string, error) {
+	b, err := os.ReadFile(tokenPath)
 	if err != nil {
-		return nil, fmt.Errorf("while unmarshalling response body: %w", err)
+		return "", fmt.Errorf("failed to read jwt token: %w", err)
 	}
 
-	return response, nil
+	jwt := string(bytes.TrimSpace(b))
+	if jwt == "" {
+		return "", fmt.Errorf("jwt token in %s is empty", tokenPath)
+	}
+	return jwt, nil
 }
 
-// k8sLogin handles converting the service path and role to a bytes buffer
-func k8sLogin(k8ServicePath string, role string) (*bytes.Buffer, error) {
-	jwt, err := getJWT(k8ServicePath)
+// checkAudience checks that the JSON web token is issued for the audience. The signature is not verified, that is done
+// by Vault. The point is to fail with a clear error if the wrong token is mounted.
+func checkAudience(jwt, audience string) error {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return errors.New("not a jwt token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("while decoding jwt token: %w", err)
 	}
 
-	return loginBuffer(&k8sToken{
-		JWT:  jwt,
-		Role: role,
-	})
+	var claims struct {
+		Aud any `json:"aud"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return fmt.Errorf("while decoding jwt token: %w", err)
+	}
+
+	var auds []string
+	switch aud := claims.Aud.(type) {
+	case string:
+		auds = []string{aud}
+	case []any:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				auds = append(auds, s)
+			}
+		}
+	}
+	for _, a := range auds {
+		if a == audience {
+			return nil
+		}
+	}
+	return fmt.Errorf("issued for audience %v, not %s", auds, audience)
 }
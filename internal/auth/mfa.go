@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// MFAProvider is called when a login requires MFA, once for each MFA method that must be completed. For methods that
+// use a passcode, such as TOTP, it returns the passcode. For methods that don't, such as Duo or Okta push, the login is
+// approved on another device, and the returned passcode is ignored. The provider can tell the user to do so.
+type MFAProvider func(method MFAMethod) (string, error)
+
+// MFAMethod is an MFA method that can satisfy an MFA requirement of a login.
+type MFAMethod struct {
+	// Type is the type of the method, e.g. "totp", "duo", "okta" or "pingid".
+	Type string `json:"type"`
+
+	// ID is the ID of the method in Vault.
+	ID string `json:"id"`
+
+	// Name is the name of the method, if it has one.
+	Name string `json:"name"`
+
+	// UsesPasscode is true if the user must enter a passcode, and false if the login is approved on another device.
+	UsesPasscode bool `json:"uses_passcode"`
+}
+
+// mfaRequirement is the MFA requirement returned by a login that must be validated before the token is issued.
+type mfaRequirement struct {
+	RequestID   string                   `json:"mfa_request_id"`
+	Constraints map[string]mfaConstraint `json:"mfa_constraints"`
+}
+
+// mfaConstraint is satisfied by any of its methods.
+type mfaConstraint struct {
+	Any []MFAMethod `json:"any"`
+}
+
+// mfaValidation is the request body of sys/mfa/validate.
+type mfaValidation struct {
+	RequestID string              `json:"mfa_request_id"`
+	Payload   map[string][]string `json:"mfa_payload"`
+}
+
+// validateMFA satisfies each constraint of the requirement with its first method, and returns the response with the
+// token. Passcodes are obtained from the MFA provider of the client, while for methods without passcodes, Vault waits
+// for the login to be approved on another device before responding.
+func (c *Client) validateMFA(ctx context.Context, req *mfaRequirement) (authenticationResponse, error) {
+	names := make([]string, 0, len(req.Constraints))
+	for name := range req.Constraints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	payload := make(map[string][]string, len(names))
+	for _, name := range names {
+		constraint := req.Constraints[name]
+		if len(constraint.Any) == 0 {
+			return authenticationResponse{}, fmt.Errorf("MFA constraint %s has no methods", name)
+		}
+
+		m := constraint.Any[0]
+		if m.Name == "" {
+			m.Name = name
+		}
+		if c.MFAProvider == nil && m.UsesPasscode {
+			return authenticationResponse{}, fmt.Errorf("login requires a passcode for %s MFA, but no MFA provider is given", m.Type)
+		}
+
+		code := ""
+		if c.MFAProvider != nil {
+			p, err := c.MFAProvider(m)
+			if err != nil {
+				return authenticationResponse{}, fmt.Errorf("while completing %s MFA: %w", m.Type, err)
+			}
+			if m.UsesPasscode {
+				code = p
+			}
+		}
+		payload[m.ID] = []string{code}
+	}
+
+	body, err := loginBuffer(mfaValidation{RequestID: req.RequestID, Payload: payload})
+	if err != nil {
+		return authenticationResponse{}, err
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, makeURL(c.Address, "sys/mfa/validate"), body)
+	if err != nil {
+		return authenticationResponse{}, fmt.Errorf("while building http request: %w", err)
+	}
+	r.Header.Set("Content-Type", "application/json")
+
+	response, err := c.do(r)
+	if err != nil {
+		return authenticationResponse{}, fmt.Errorf("while validating MFA: %w", err)
+	}
+	if response.Auth.MFARequirement != nil {
+		return authenticationResponse{}, errors.New("MFA validation did not complete the login")
+	}
+
+	return response, nil
+}
@@ -2,7 +2,9 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/3lvia/hashivault-go/internal/logging"
 	"github.com/hashicorp/cap/util"
 	"github.com/hashicorp/go-secure-stdlib/base62"
 	"github.com/hashicorp/vault/api"
@@ -11,12 +13,12 @@ import (
 	"go.opentelemetry.io/otel/trace"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"regexp"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
@@ -27,6 +29,9 @@ const (
 	defaultCallbackHost   = "localhost"
 	defaultCallbackMethod = "http"
 
+	// defaultLoginTimeout is how long to wait for the user to complete the login at the OIDC provider.
+	defaultLoginTimeout = 5 * time.Minute
+
 	FieldCallbackHost   = "callbackhost"
 	FieldCallbackMethod = "callbackmethod"
 	FieldListenAddress  = "listenaddress"
@@ -49,81 +54,186 @@ const (
 
 var errorRegex = regexp.MustCompile(`(?s)Errors:.*\* *(.*)`)
 
-type oicdResponse struct {
-	s *api.Secret
+// oidcAuthenticator logs in with OIDC, in a browser on the same machine or, if headless, by having the user paste
+// the callback URL. The token is cached across process restarts if cache is true.
+type oidcAuthenticator struct {
+	cfg      OIDCConfig
+	headless bool
+	cache    bool
+	l        *logging.Logger
 }
 
-func (r oicdResponse) ClientToken() string {
-	return r.s.Auth.ClientToken
+func (a oidcAuthenticator) Login(ctx context.Context, c *Client) (AuthResult, error) {
+	return a.login(ctx, c, true)
 }
 
-func (r oicdResponse) LeaseDurationSeconds() int {
-	return r.s.Auth.LeaseDuration
-}
+// Renew renews the token with renew-self, so that the user is only asked to log in again once the token cannot be
+// renewed any further. The cached token is updated with the new expiry.
+func (a oidcAuthenticator) Renew(ctx context.Context, c *Client, token string) (AuthResult, error) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(ctx, "auth.oidcAuthenticator.Renew", trace.WithAttributes(attribute.String("vault_addr", c.Address)))
+	defer span.End()
 
-func (r oicdResponse) Renewable() bool {
-	return r.s.Auth.Renewable
-}
+	r, ok, err := c.renewToken(spanCtx, token)
+	if err != nil {
+		traceError(span, err)
+		return AuthResult{}, err
+	}
+	if !ok {
+		// the cached token is the one that cannot be renewed, so it is not reused
+		a.l.Print("oidc token cannot be renewed any further, logging in again")
+		return a.login(spanCtx, c, false)
+	}
 
-func (r oicdResponse) After() <-chan time.Time {
-	return time.After(time.Duration(r.s.Auth.LeaseDuration) * time.Second)
+	if a.cache {
+		a.save(c, r)
+	}
+	return r, nil
 }
 
-func authOICD(ctx context.Context, addr string) (AuthenticationResponse, error) {
-	tracer := otel.GetTracerProvider().Tracer(tracerName)
-	_, span := tracer.Start(ctx, "auth.authOICD", trace.WithAttributes(attribute.String("vault_addr", addr)))
-	defer span.End()
+// login logs in with OIDC, reusing the cached token if useCached is true. The new token is cached if caching is
+// enabled.
+func (a oidcAuthenticator) login(ctx context.Context, c *Client, useCached bool) (AuthResult, error) {
+	login := func() (AuthResult, error) {
+		if a.headless {
+			return authOICDHeadless(ctx, c, a.cfg, os.Stdin, os.Stderr)
+		}
+		return authOICD(ctx, c, a.cfg)
+	}
+	if !a.cache {
+		return login()
+	}
 
-	doneCh := make(chan loginResp)
-	var resp loginResp
+	if useCached {
+		cache, err := newOIDCCache(c.Address, a.cfg, a.l)
+		if err != nil {
+			a.l.Printf("not caching oidc token: %s", err)
+			return login()
+		}
+		if r, ok := cache.load(ctx, c); ok {
+			return r, nil
+		}
+	}
 
-	client, err := api.NewClient(&api.Config{
-		Address: addr,
-	})
+	r, err := login()
 	if err != nil {
-		return nil, err
+		return AuthResult{}, err
 	}
+	a.save(c, r)
+	return r, nil
+}
+
+// save caches the token, so that it is reused across process restarts until it expires.
+func (a oidcAuthenticator) save(c *Client, r AuthResult) {
+	cache, err := newOIDCCache(c.Address, a.cfg, a.l)
+	if err == nil {
+		err = cache.save(r)
+	}
+	if err != nil {
+		a.l.Printf("error caching oidc token: %s", err)
+	}
+}
 
-	errChan := make(chan error)
-	go func(ec chan<- error, port string) {
-		pp := ":" + port
-		if err := http.ListenAndServe(pp, nil); err != nil {
-			ec <- err
-			return
+// secretResult returns the token in the secret from an OIDC login as an AuthResult. If the login requires MFA, it is
+// validated first.
+func (c *Client) secretResult(ctx context.Context, s *api.Secret) (AuthResult, error) {
+	if req := s.Auth.MFARequirement; req != nil {
+		mfa := &mfaRequirement{RequestID: req.MFARequestID, Constraints: map[string]mfaConstraint{}}
+		for name, constraint := range req.MFAConstraints {
+			var methods []MFAMethod
+			for _, m := range constraint.Any {
+				methods = append(methods, MFAMethod{Type: m.Type, ID: m.ID, Name: m.Name, UsesPasscode: m.UsesPasscode})
+			}
+			mfa.Constraints[name] = mfaConstraint{Any: methods}
 		}
-	}(errChan, defaultPort)
 
-	go func(ch chan<- loginResp, c *api.Client) {
-		h := &oicdHandler{doneCh: ch}
-		v := map[string]string{}
-		h.Auth(c, v)
-	}(doneCh, client)
+		response, err := c.validateMFA(ctx, mfa)
+		if err != nil {
+			return AuthResult{}, err
+		}
+		return response.result(), nil
+	}
+
+	return AuthResult{
+		Token:         s.Auth.ClientToken,
+		LeaseDuration: time.Duration(s.Auth.LeaseDuration) * time.Second,
+		Renewable:     s.Auth.Renewable,
+	}, nil
+}
 
-	wg := &sync.WaitGroup{}
-	wg.Add(1)
+// OIDCConfig configures the OIDC flow. Empty fields take the default values.
+type OIDCConfig struct {
+	Role          string
+	Mount         string
+	ListenAddress string
+	Port          string
+	CallbackHost  string
+	SkipBrowser   bool
 
-	func(ch <-chan loginResp, ec <-chan error, w *sync.WaitGroup) {
-		defer w.Done()
-		select {
-		case resp = <-ch:
-			// do nothing
-		case err := <-ec:
-			resp = loginResp{err: err}
+	// Timeout is how long to wait for the user to complete the login, five minutes by default.
+	Timeout time.Duration
+
+	// AuthURLHandler, if set, receives the URL to complete the login at instead of it being printed to stderr and
+	// opened in the browser.
+	AuthURLHandler func(authURL string)
+}
+
+// fields returns the configuration in the form used by oicdHandler.Auth.
+func (c OIDCConfig) fields() map[string]string {
+	m := map[string]string{}
+	set := func(k, v string) {
+		if v != "" {
+			m[k] = v
 		}
-	}(doneCh, errChan, wg)
+	}
+	set("role", c.Role)
+	set("mount", c.Mount)
+	set(FieldListenAddress, c.ListenAddress)
+	set(FieldPort, c.Port)
+	set(FieldCallbackHost, c.CallbackHost)
+	if c.SkipBrowser || c.AuthURLHandler != nil {
+		m[FieldSkipBrowser] = "true"
+	}
+	return m
+}
 
-	wg.Wait()
+func authOICD(ctx context.Context, c *Client, cfg OIDCConfig) (AuthResult, error) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(ctx, "auth.authOICD", trace.WithAttributes(attribute.String("vault_addr", c.Address)))
+	defer span.End()
 
-	if resp.err != nil {
-		return nil, resp.err
+	client, err := api.NewClient(&api.Config{
+		Address: c.Address,
+	})
+	if err != nil {
+		return AuthResult{}, err
+	}
+
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultLoginTimeout
 	}
+	loginCtx, cancel := context.WithTimeout(spanCtx, timeout)
+	defer cancel()
 
-	finalResp := oicdResponse{s: resp.secret}
-	return finalResp, nil
+	h := &oicdHandler{authURLHandler: cfg.AuthURLHandler}
+	secret, err := h.Auth(loginCtx, client, cfg.fields())
+	if err != nil {
+		traceError(span, err)
+		return AuthResult{}, err
+	}
+
+	r, err := c.secretResult(spanCtx, secret)
+	if err != nil {
+		traceError(span, err)
+		return AuthResult{}, err
+	}
+
+	return r, nil
 }
 
 type oicdHandler struct {
-	doneCh chan<- loginResp
+	authURLHandler func(authURL string)
 }
 
 // loginResp implements vault's command.LoginHandler interface, but we do not check
@@ -133,7 +243,9 @@ type loginResp struct {
 	err    error
 }
 
-func (h *oicdHandler) Auth(c *api.Client, m map[string]string) {
+// Auth runs the OIDC flow: it starts a callback server, has the user complete the login at the OIDC provider, and
+// waits for the callback until ctx is done. The callback server is shut down before Auth returns.
+func (h *oicdHandler) Auth(ctx context.Context, c *api.Client, m map[string]string) (*api.Secret, error) {
 	mount, ok := m["mount"]
 	if !ok {
 		mount = defaultMount
@@ -179,54 +291,73 @@ func (h *oicdHandler) Auth(c *api.Client, m map[string]string) {
 		return v, nil
 	}
 
-	var skipBrowserLaunch bool
-	if v, err := parseBool(FieldSkipBrowser, false); err != nil {
-		h.doneCh <- loginResp{err: err}
-		return
-	} else {
-		skipBrowserLaunch = v
+	skipBrowserLaunch, err := parseBool(FieldSkipBrowser, false)
+	if err != nil {
+		return nil, err
 	}
 
-	var abortOnError bool
-	if v, err := parseBool(FieldAbortOnError, false); err != nil {
-		h.doneCh <- loginResp{err: err}
-		return
-	} else {
-		abortOnError = v
+	abortOnError, err := parseBool(FieldAbortOnError, false)
+	if err != nil {
+		return nil, err
 	}
 
 	role := m["role"]
 
 	authURL, clientNonce, err := fetchAuthURL(c, role, mount, callbackPort, callbackMethod, callbackHost)
 	if err != nil {
-		h.doneCh <- loginResp{err: err}
-		return
+		return nil, err
 	}
 
-	// Set up callback handler
-	http.HandleFunc("/oidc/callback", callbackHandler(c, mount, clientNonce, h.doneCh))
+	// Set up the callback server. It has its own mux, so that logging in more than once in the same process does not
+	// register the handler twice, and it only listens on the configured address.
+	doneCh := make(chan loginResp, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oidc/callback", callbackHandler(c, mount, clientNonce, doneCh))
 
-	listener, err := net.Listen("tcp", listenAddress+":"+port)
+	listener, err := net.Listen("tcp", net.JoinHostPort(listenAddress, port))
 	if err != nil {
-		h.doneCh <- loginResp{err: err}
-		return
+		return nil, fmt.Errorf("while starting oidc callback server: %w", err)
 	}
-	defer listener.Close()
+
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	serveErr := make(chan error, 1)
+	go func() {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+	}()
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
 
 	// Open the default browser to the callback URL.
-	if !skipBrowserLaunch {
+	switch {
+	case h.authURLHandler != nil:
+		h.authURLHandler(authURL)
+	case !skipBrowserLaunch:
 		fmt.Fprintf(os.Stderr, "Complete the login via your OIDC provider. Launching browser to:\n\n    %s\n\n\n", authURL)
 		if err := util.OpenURL(authURL); err != nil {
 			if abortOnError {
-				h.doneCh <- loginResp{err: fmt.Errorf("failed to launch the browser %s=%t, err=%w", FieldAbortOnError, abortOnError, err)}
-				return
+				return nil, fmt.Errorf("failed to launch the browser %s=%t, err=%w", FieldAbortOnError, abortOnError, err)
 			}
 			fmt.Fprintf(os.Stderr, "Error attempting to automatically open browser: '%s'.\nPlease visit the authorization URL manually.", err)
 		}
-	} else {
+		fmt.Fprintf(os.Stderr, "Waiting for OIDC authentication to complete...\n")
+	default:
 		fmt.Fprintf(os.Stderr, "Complete the login via your OIDC provider. Open the following link in your browser:\n\n    %s\n\n\n", authURL)
+		fmt.Fprintf(os.Stderr, "Waiting for OIDC authentication to complete...\n")
+	}
+
+	select {
+	case resp := <-doneCh:
+		return resp.secret, resp.err
+	case err := <-serveErr:
+		return nil, fmt.Errorf("oidc callback server failed: %w", err)
+	case <-ctx.Done():
+		return nil, fmt.Errorf("oidc login not completed: %w", ctx.Err())
 	}
-	fmt.Fprintf(os.Stderr, "Waiting for OIDC authentication to complete...\n")
 }
 
 func fetchAuthURL(c *api.Client, role, mount, callbackPort string, callbackMethod string, callbackHost string) (string, string, error) {
@@ -262,49 +393,64 @@ func fetchAuthURL(c *api.Client, role, mount, callbackPort string, callbackMetho
 
 func callbackHandler(c *api.Client, mount string, clientNonce string, doneCh chan<- loginResp) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		var response string
-		var secret *api.Secret
-		var err error
-
-		defer func() {
-			w.Write([]byte(response))
-			doneCh <- loginResp{secret, err}
-		}()
-
 		// Pull any parameters from either the body or query parameters.
 		// FormValue prioritizes body values, if found.
-		data := map[string][]string{
-			"state":        {req.FormValue("state")},
-			"code":         {req.FormValue("code")},
-			"id_token":     {req.FormValue("id_token")},
-			"client_nonce": {clientNonce},
+		form := url.Values{
+			"state":    {req.FormValue("state")},
+			"code":     {req.FormValue("code")},
+			"id_token": {req.FormValue("id_token")},
 		}
 
-		// If this is a POST, then the form_post response_mode is being used and the flow
-		// involves an extra step. First POST the data to Vault, and then issue a GET with
-		// the same state/code to complete the auth as normal.
-		if req.Method == http.MethodPost {
-			url := c.Address() + path.Join("/v1/auth", mount, "oidc/callback")
-			resp, err := http.PostForm(url, data)
-			if err != nil {
-				summary, detail := parseError(err)
-				response = errorHTML(summary, detail)
-				return
-			}
-			defer resp.Body.Close()
-
-			// An id_token will never be part of a redirect GET, so remove it here too.
-			delete(data, "id_token")
-		}
-
-		secret, err = c.Logical().ReadWithData(fmt.Sprintf("auth/%s/oidc/callback", mount), data)
+		var response string
+		secret, err := completeLogin(c, mount, clientNonce, form, req.Method == http.MethodPost)
 		if err != nil {
 			summary, detail := parseError(err)
 			response = errorHTML(summary, detail)
 		} else {
 			response = successHTML
 		}
+
+		w.Write([]byte(response))
+		// Only the first callback completes the login, later ones (e.g. a reloaded page) are ignored.
+		select {
+		case doneCh <- loginResp{secret, err}:
+		default:
+		}
+	}
+}
+
+// completeLogin exchanges the state and code returned by the OIDC provider for a Vault token.
+func completeLogin(c *api.Client, mount, clientNonce string, form url.Values, post bool) (*api.Secret, error) {
+	data := map[string][]string{
+		"state":        {form.Get("state")},
+		"code":         {form.Get("code")},
+		"id_token":     {form.Get("id_token")},
+		"client_nonce": {clientNonce},
+	}
+
+	// If this is a POST, then the form_post response_mode is being used and the flow
+	// involves an extra step. First POST the data to Vault, and then issue a GET with
+	// the same state/code to complete the auth as normal.
+	if post {
+		callbackURL := c.Address() + path.Join("/v1/auth", mount, "oidc/callback")
+		resp, err := http.PostForm(callbackURL, data)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		// An id_token will never be part of a redirect GET, so remove it here too.
+		delete(data, "id_token")
+	}
+
+	secret, err := c.Logical().ReadWithData(fmt.Sprintf("auth/%s/oidc/callback", mount), data)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Auth == nil {
+		return nil, errors.New("no token in response from oidc callback")
 	}
+	return secret, nil
 }
 
 // parseError converts error from the API into summary and detailed portions.
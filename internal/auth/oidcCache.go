@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/3lvia/hashivault-go/internal/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// oidcCacheDir is the directory under the user config dir where OIDC tokens are cached.
+	oidcCacheDir = "hashivault"
+
+	// oidcCacheKeyFile is the file in the home directory with the key used to encrypt cached tokens. The key is
+	// optional; if the file does not exist, tokens are cached unencrypted in a file only readable by the user.
+	oidcCacheKeyFile = ".hashivault-key"
+
+	// oidcCacheMinTTL is the minimum remaining lifetime of a cached token for it to be reused.
+	oidcCacheMinTTL = time.Minute
+)
+
+// oidcCache caches OIDC tokens across process restarts, so that the browser flow is only needed when the cached
+// token has expired or been revoked.
+type oidcCache struct {
+	path string
+	key  []byte
+	l    *logging.Logger
+}
+
+// cachedToken is the content of the cache file.
+type cachedToken struct {
+	ClientToken string    `json:"client_token"`
+	Expires     time.Time `json:"expires"`
+}
+
+// newOIDCCache returns the cache for tokens issued by the Vault server at addr. Each Vault server, mount and role has
+// its own file.
+func newOIDCCache(addr string, cfg OIDCConfig, l *logging.Logger) (*oidcCache, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(addr + "\x00" + cfg.Mount + "\x00" + cfg.Role))
+	c := &oidcCache{
+		path: filepath.Join(configDir, oidcCacheDir, "oidc-token-"+hex.EncodeToString(sum[:8])),
+		l:    l,
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		b, err := os.ReadFile(filepath.Join(home, oidcCacheKeyFile))
+		if err == nil {
+			key := sha256.Sum256(b)
+			c.key = key[:]
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("while reading oidc cache key: %w", err)
+		}
+	}
+
+	return c, nil
+}
+
+// load returns the cached token if it is still valid according to Vault.
+func (c *oidcCache) load(ctx context.Context, client *Client) (AuthResult, bool) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(ctx, "auth.oidcCache.load", trace.WithAttributes(attribute.String("path", c.path)))
+	defer span.End()
+
+	b, err := os.ReadFile(c.path)
+	if err != nil {
+		return AuthResult{}, false
+	}
+	if c.key != nil {
+		if b, err = c.decrypt(b); err != nil {
+			c.l.Printf("ignoring cached oidc token: %s", err)
+			return AuthResult{}, false
+		}
+	}
+
+	var t cachedToken
+	if err := json.Unmarshal(b, &t); err != nil || t.ClientToken == "" {
+		c.l.Printf("ignoring invalid cached oidc token in %s", c.path)
+		return AuthResult{}, false
+	}
+	if time.Until(t.Expires) < oidcCacheMinTTL {
+		c.l.Print("cached oidc token has expired")
+		return AuthResult{}, false
+	}
+
+	r, err := LookupSelf(spanCtx, client, t.ClientToken)
+	if err != nil {
+		c.l.Printf("cached oidc token is no longer valid: %s", err)
+		return AuthResult{}, false
+	}
+	if r.LeaseDuration > 0 && r.LeaseDuration < oidcCacheMinTTL {
+		c.l.Print("cached oidc token is about to expire")
+		return AuthResult{}, false
+	}
+
+	c.l.Printf("using cached oidc token from %s", c.path)
+	return r.AuthResult, true
+}
+
+// save writes the token to the cache file, readable only by the user.
+func (c *oidcCache) save(r AuthResult) error {
+	expires := time.Now().Add(r.LeaseDuration)
+	if r.LeaseDuration == 0 {
+		expires = time.Now().Add(365 * 24 * time.Hour)
+	}
+
+	b, err := json.Marshal(cachedToken{ClientToken: r.Token, Expires: expires})
+	if err != nil {
+		return err
+	}
+	if c.key != nil {
+		if b, err = c.encrypt(b); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return fmt.Errorf("while creating oidc cache dir: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".oidc-token-*")
+	if err != nil {
+		return fmt.Errorf("while caching oidc token: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("while caching oidc token: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("while caching oidc token: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("while caching oidc token: %w", err)
+	}
+
+	c.l.Printf("cached oidc token in %s", c.path)
+	return nil
+}
+
+// encrypt seals the plaintext with AES-GCM, prefixing it with the nonce.
+func (c *oidcCache) encrypt(plaintext []byte) ([]byte, error) {
+	gcm, err := c.gcm()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (c *oidcCache) decrypt(ciphertext []byte) ([]byte, error) {
+	gcm, err := c.gcm()
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("cached oidc token is too short")
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, sealed, nil)
+}
+
+func (c *oidcCache) gcm() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
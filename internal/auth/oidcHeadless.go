@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/vault/api"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// authOICDHeadless runs the OIDC flow without a callback server, for machines where the browser cannot reach
+// localhost, such as remote VMs and devcontainers. The user opens the auth URL in a browser anywhere, and when the
+// browser fails to load the redirect to localhost, pastes the URL from its address bar. The state and code in the URL
+// complete the login.
+func authOICDHeadless(ctx context.Context, c *Client, cfg OIDCConfig, in io.Reader, out io.Writer) (AuthResult, error) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(ctx, "auth.authOICDHeadless", trace.WithAttributes(attribute.String("vault_addr", c.Address)))
+	defer span.End()
+
+	client, err := api.NewClient(&api.Config{
+		Address: c.Address,
+	})
+	if err != nil {
+		return AuthResult{}, err
+	}
+
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultLoginTimeout
+	}
+	loginCtx, cancel := context.WithTimeout(spanCtx, timeout)
+	defer cancel()
+
+	m := cfg.fields()
+	mount, ok := m["mount"]
+	if !ok {
+		mount = defaultMount
+	}
+	port, ok := m[FieldPort]
+	if !ok {
+		port = defaultPort
+	}
+	callbackHost, ok := m[FieldCallbackHost]
+	if !ok {
+		callbackHost = defaultCallbackHost
+	}
+
+	authURL, clientNonce, err := fetchAuthURL(client, m["role"], mount, port, defaultCallbackMethod, callbackHost)
+	if err != nil {
+		traceError(span, err)
+		return AuthResult{}, err
+	}
+
+	if cfg.AuthURLHandler != nil {
+		cfg.AuthURLHandler(authURL)
+	} else {
+		fmt.Fprintf(out, "Complete the login via your OIDC provider. Open the following link in a browser:\n\n    %s\n\n", authURL)
+	}
+	fmt.Fprintf(out, "The browser is then redirected to a page on localhost that cannot be loaded. Paste the URL of that page here:\n")
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-loginCtx.Done():
+				return
+			}
+		}
+		close(lines)
+	}()
+
+	for {
+		var line string
+		select {
+		case <-loginCtx.Done():
+			return AuthResult{}, fmt.Errorf("oidc login not completed: %w", loginCtx.Err())
+		case l, ok := <-lines:
+			if !ok {
+				err := errors.New("oidc login not completed: no callback URL given")
+				traceError(span, err)
+				return AuthResult{}, err
+			}
+			line = l
+		}
+
+		form, err := callbackParams(line)
+		if err != nil {
+			fmt.Fprintf(out, "%s, please try again:\n", err)
+			continue
+		}
+
+		secret, err := completeLogin(client, mount, clientNonce, form, false)
+		if err != nil {
+			traceError(span, err)
+			return AuthResult{}, err
+		}
+
+		r, err := c.secretResult(spanCtx, secret)
+		if err != nil {
+			traceError(span, err)
+			return AuthResult{}, err
+		}
+
+		fmt.Fprintf(out, "Login completed.\n")
+		return r, nil
+	}
+}
+
+// callbackParams returns the state and code in the pasted callback URL. The query string alone is accepted as well.
+func callbackParams(s string) (url.Values, error) {
+	s = strings.TrimSpace(s)
+	if i := strings.Index(s, "?"); i >= 0 {
+		s = s[i+1:]
+	}
+
+	form, err := url.ParseQuery(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid callback URL: %w", err)
+	}
+	if form.Get("error") != "" {
+		return nil, fmt.Errorf("login failed at the OIDC provider: %s", form.Get("error_description"))
+	}
+	if form.Get("state") == "" || form.Get("code") == "" {
+		return nil, errors.New("no state and code found in the callback URL")
+	}
+
+	return form, nil
+}
@@ -1,7 +1,7 @@
 package auth
 
 import (
-	"log"
+	"github.com/3lvia/hashivault-go/internal/logging"
 	"net/http"
 )
 
@@ -10,10 +10,20 @@ type optionsCollector struct {
 
 	gitHubToken string
 
-	k8sServicePath string
-	k8sRole        string
+	k8s K8sConfig
 
-	l              *log.Logger
+	wrappedToken string
+
+	oidcCache  bool
+	oidcConfig OIDCConfig
+
+	password PasswordConfig
+
+	custom Authenticator
+
+	mfa MFAProvider
+
+	l              *logging.Logger
 	otelTracerName string
 }
 
@@ -37,12 +47,62 @@ func WithGitHubToken(token string) Option {
 // WithK8s sets the Kubernetes service path and role to use for authentication
 func WithK8s(servicePath, role string) Option {
 	return func(o *optionsCollector) {
-		o.k8sServicePath = servicePath
-		o.k8sRole = role
+		o.k8s.Mount = servicePath
+		o.k8s.Role = role
+	}
+}
+
+// WithK8sConfig sets the mount, role, token path and audience to use for Kubernetes authentication
+func WithK8sConfig(cfg K8sConfig) Option {
+	return func(o *optionsCollector) {
+		o.k8s = cfg
+	}
+}
+
+// WithWrappedToken sets the response-wrapping token to unwrap for authentication
+func WithWrappedToken(token string) Option {
+	return func(o *optionsCollector) {
+		o.wrappedToken = token
+	}
+}
+
+// WithOIDCCache sets whether OIDC tokens are cached in the user config dir, so that they can be reused across
+// process restarts
+func WithOIDCCache(enabled bool) Option {
+	return func(o *optionsCollector) {
+		o.oidcCache = enabled
+	}
+}
+
+// WithOIDCConfig sets the role, mount, callback and browser settings of the OIDC flow
+func WithOIDCConfig(cfg OIDCConfig) Option {
+	return func(o *optionsCollector) {
+		o.oidcConfig = cfg
+	}
+}
+
+// WithPassword sets the username and password to use with MethodUserpass and MethodLDAP
+func WithPassword(cfg PasswordConfig) Option {
+	return func(o *optionsCollector) {
+		o.password = cfg
+	}
+}
+
+// WithMFAProvider sets the MFA provider that completes the MFA of logins that require it
+func WithMFAProvider(p MFAProvider) Option {
+	return func(o *optionsCollector) {
+		o.mfa = p
+	}
+}
+
+// WithAuthenticator sets the authenticator to use with MethodCustom
+func WithAuthenticator(a Authenticator) Option {
+	return func(o *optionsCollector) {
+		o.custom = a
 	}
 }
 
-func WithLogger(l *log.Logger) Option {
+func WithLogger(l *logging.Logger) Option {
 	return func(o *optionsCollector) {
 		o.l = l
 	}
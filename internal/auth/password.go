@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PasswordConfig configures username and password authentication with the userpass or LDAP auth method.
+type PasswordConfig struct {
+	// Mount is the path where the auth method is mounted, "userpass" or "ldap" by default.
+	Mount string
+
+	// Username is the name of the user to log in as.
+	Username string
+
+	// Password is the password of the user. If it is empty, the password is obtained from PasswordPrompt.
+	Password string
+
+	// PasswordPrompt is called to obtain the password if none is given, e.g. by reading it from the terminal.
+	PasswordPrompt func(username string) (string, error)
+}
+
+// passwordAuthenticator logs in with a username and password using the userpass or LDAP auth method.
+type passwordAuthenticator struct {
+	method Method
+	cfg    PasswordConfig
+}
+
+func (a passwordAuthenticator) Login(ctx context.Context, c *Client) (AuthResult, error) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(
+		ctx,
+		"auth.authPassword",
+		trace.WithAttributes(attribute.String("vault_addr", c.Address), attribute.String("method", methodToString(a.method))))
+	defer span.End()
+
+	if a.cfg.Username == "" {
+		err := errors.New("no username provided")
+		traceError(span, err)
+		return AuthResult{}, err
+	}
+
+	password := a.cfg.Password
+	if password == "" && a.cfg.PasswordPrompt != nil {
+		p, err := a.cfg.PasswordPrompt(a.cfg.Username)
+		if err != nil {
+			traceError(span, err)
+			return AuthResult{}, fmt.Errorf("while reading password: %w", err)
+		}
+		password = p
+	}
+	if password == "" {
+		err := errors.New("no password provided")
+		traceError(span, err)
+		return AuthResult{}, err
+	}
+
+	r, err := c.Login(spanCtx, "auth/"+a.mount()+"/login/"+a.cfg.Username, map[string]string{"password": password})
+	if err != nil {
+		traceError(span, err)
+		return AuthResult{}, err
+	}
+
+	return r, nil
+}
+
+// Renew renews the token with renew-self, so that the user is only prompted for the password again once the token
+// cannot be renewed any further.
+func (a passwordAuthenticator) Renew(ctx context.Context, c *Client, token string) (AuthResult, error) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(
+		ctx,
+		"auth.passwordAuthenticator.Renew",
+		trace.WithAttributes(attribute.String("vault_addr", c.Address), attribute.String("method", methodToString(a.method))))
+	defer span.End()
+
+	r, ok, err := c.renewToken(spanCtx, token)
+	if err != nil {
+		traceError(span, err)
+		return AuthResult{}, err
+	}
+	if !ok {
+		return a.Login(spanCtx, c)
+	}
+
+	return r, nil
+}
+
+// mount returns the mount path of the auth method.
+func (a passwordAuthenticator) mount() string {
+	if a.cfg.Mount != "" {
+		return a.cfg.Mount
+	}
+	if a.method == MethodLDAP {
+		return "ldap"
+	}
+	return "userpass"
+}
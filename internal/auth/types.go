@@ -18,8 +18,29 @@ const (
 
 	// MethodToken is the authentication method where a Vault token has been obtained elsewhere and is used directly.
 	MethodToken
+
+	// MethodWrappedToken is the authentication method where a single-use response-wrapping token is unwrapped to
+	// obtain a Vault token.
+	MethodWrappedToken
+
+	// MethodOICDHeadless is the OpenID Connect authentication method for machines where the browser cannot reach the
+	// callback on localhost. The user pastes the URL of the callback into the terminal instead.
+	MethodOICDHeadless
+
+	// MethodCustom is the authentication method where an Authenticator given with WithAuthenticator is used.
+	MethodCustom
+
+	// MethodUserpass is the authentication method where a username and password are used to authenticate the user.
+	MethodUserpass
+
+	// MethodLDAP is the authentication method where LDAP credentials are used to authenticate the user.
+	MethodLDAP
 )
 
+func (m Method) String() string {
+	return methodToString(m)
+}
+
 func methodToString(m Method) string {
 	switch m {
 	case MethodGitHub:
@@ -30,6 +51,16 @@ func methodToString(m Method) string {
 		return "OIDC"
 	case MethodToken:
 		return "Token"
+	case MethodWrappedToken:
+		return "WrappedToken"
+	case MethodOICDHeadless:
+		return "OIDC (headless)"
+	case MethodCustom:
+		return "Custom"
+	case MethodUserpass:
+		return "Userpass"
+	case MethodLDAP:
+		return "LDAP"
 	default:
 		return "Unknown"
 	}
@@ -56,10 +87,6 @@ type AuthenticationResponse interface {
 
 	// Renewable is true if the token is renewable.
 	Renewable() bool
-
-	// After returns a channel that fire when the token is about to expire. The channel will fire when the token has
-	// 30 seconds left to live.
-	After() <-chan time.Time
 }
 
 type authenticationResponse struct {
@@ -85,17 +112,13 @@ func (a authenticationResponse) Renewable() bool {
 	return a.Auth.Renewable
 }
 
-func (a authenticationResponse) After() <-chan time.Time {
-	secs := a.Auth.LeaseDuration
-	if secs == 0 {
-		secs = 3600 * 24 * 365 // 1 year
-	}
-	if secs >= 60 {
-		secs -= 30 // 30 seconds leeway
-	} else {
-		secs = 1 // 1 second leeway
+// result returns the token in the response as an AuthResult.
+func (a authenticationResponse) result() AuthResult {
+	return AuthResult{
+		Token:         a.Auth.ClientToken,
+		LeaseDuration: time.Duration(a.Auth.LeaseDuration) * time.Second,
+		Renewable:     a.Auth.Renewable,
 	}
-	return time.After(time.Duration(secs) * time.Second)
 }
 
 type authenticationData struct {
@@ -109,6 +132,6 @@ type authenticationData struct {
 	EntityID       string                 `json:"entity_id"`
 	TokenType      string                 `json:"token_type"`
 	Orphan         bool                   `json:"orphan"`
-	MFARequirement interface{}            `json:"mfa_requirement"`
+	MFARequirement *mfaRequirement        `json:"mfa_requirement"`
 	NumUses        int                    `json:"num_uses"`
 }
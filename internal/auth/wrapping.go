@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// wrappedTokenAuthenticator unwraps a response-wrapped token, e.g. one created with
+// "vault token create -wrap-ttl=5m". The wrapping token can only be used once, so the unwrapped token is renewed
+// rather than unwrapped again.
+type wrappedTokenAuthenticator struct {
+	token string
+}
+
+func (a wrappedTokenAuthenticator) Login(ctx context.Context, c *Client) (AuthResult, error) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(ctx, "auth.authWrappedToken", trace.WithAttributes(attribute.String("vault_addr", c.Address)))
+	defer span.End()
+
+	response, err := c.tokenRequest(spanCtx, "sys/wrapping/unwrap", a.token)
+	if err != nil {
+		traceError(span, err)
+		return AuthResult{}, fmt.Errorf("while unwrapping token: %w", err)
+	}
+	if response.Auth.ClientToken == "" {
+		err := errors.New("wrapped response does not contain a token")
+		traceError(span, err)
+		return AuthResult{}, err
+	}
+
+	return response.result(), nil
+}
+
+func (a wrappedTokenAuthenticator) Renew(ctx context.Context, c *Client, token string) (AuthResult, error) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(ctx, "auth.RenewSelf", trace.WithAttributes(attribute.String("vault_addr", c.Address)))
+	defer span.End()
+
+	r, err := c.RenewSelf(spanCtx, token)
+	if err != nil {
+		traceError(span, err)
+		return AuthResult{}, err
+	}
+
+	return r, nil
+}
@@ -0,0 +1,109 @@
+// Package logging provides the logger used throughout the module. Messages are written as they always have been to a
+// *log.Logger, and as structured records with fixed keys to a *slog.Logger, so that they can be emitted as JSON.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"time"
+)
+
+// The keys of the structured fields. Token and secret values must never be logged, neither in fields nor in messages.
+const (
+	KeyComponent     = "component"
+	KeyPath          = "path"
+	KeyMethod        = "method"
+	KeyStatus        = "status"
+	KeyRequestID     = "request_id"
+	KeyLeaseDuration = "lease_duration"
+	KeyError         = "error"
+)
+
+// Logger writes each message to a *log.Logger and to a *slog.Logger, either of which may be nil. The zero value and a
+// nil *Logger discard all messages.
+type Logger struct {
+	l *log.Logger
+	s *slog.Logger
+}
+
+// New returns a Logger that writes to the given loggers, either of which may be nil.
+func New(l *log.Logger, s *slog.Logger) *Logger {
+	return &Logger{l: l, s: s}
+}
+
+// With returns a Logger that adds the given fields to the structured records. The messages written to the
+// *log.Logger are unchanged.
+func (l *Logger) With(attrs ...slog.Attr) *Logger {
+	if l == nil || l.s == nil {
+		return l
+	}
+	args := make([]any, len(attrs))
+	for i, a := range attrs {
+		args[i] = a
+	}
+	return &Logger{l: l.l, s: l.s.With(args...)}
+}
+
+// Printf logs a message at info level.
+func (l *Logger) Printf(format string, v ...any) {
+	l.log(slog.LevelInfo, fmt.Sprintf(format, v...))
+}
+
+// Print logs a message at info level.
+func (l *Logger) Print(v ...any) {
+	l.log(slog.LevelInfo, fmt.Sprint(v...))
+}
+
+// Errorf logs a message at error level.
+func (l *Logger) Errorf(format string, v ...any) {
+	l.log(slog.LevelError, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) log(level slog.Level, msg string) {
+	if l == nil {
+		return
+	}
+	if l.l != nil {
+		l.l.Print(msg)
+	}
+	if l.s != nil {
+		l.s.Log(context.Background(), level, msg)
+	}
+}
+
+// Component returns the field naming the part of the module that logs.
+func Component(c string) slog.Attr {
+	return slog.String(KeyComponent, c)
+}
+
+// Path returns the field with the path of a secret in Vault, or of a file.
+func Path(p string) slog.Attr {
+	return slog.String(KeyPath, p)
+}
+
+// Method returns the field with an authentication method.
+func Method(m string) slog.Attr {
+	return slog.String(KeyMethod, m)
+}
+
+// Status returns the field with the status code of a response from Vault.
+func Status(code int) slog.Attr {
+	return slog.Int(KeyStatus, code)
+}
+
+// RequestID returns the field with the ID Vault assigned to a request.
+func RequestID(id string) slog.Attr {
+	return slog.String(KeyRequestID, id)
+}
+
+// LeaseDuration returns the field with the duration of a lease, in seconds.
+func LeaseDuration(d time.Duration) slog.Attr {
+	return slog.Int64(KeyLeaseDuration, int64(d/time.Second))
+}
+
+// Error returns the field with an error.
+func Error(err error) slog.Attr {
+	return slog.String(KeyError, err.Error())
+}
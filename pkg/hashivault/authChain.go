@@ -0,0 +1,235 @@
+package hashivault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/3lvia/hashivault-go/internal/auth"
+	"os"
+	"strings"
+)
+
+// AuthMethod identifies an authentication method in an auth chain, see WithAuthChain.
+type AuthMethod int
+
+const (
+	// AuthToken is a Vault token given with VAULT_TOKEN or WithVaultToken.
+	AuthToken AuthMethod = iota + 1
+	// AuthTokenFile is a Vault token read from the file given with VAULT_TOKEN_FILE or WithVaultTokenFile.
+	AuthTokenFile
+	// AuthWrappedToken is a response-wrapping token given with VAULT_WRAPPED_TOKEN, VAULT_WRAPPED_TOKEN_FILE,
+	// WithWrappedToken or WithWrappedTokenFile.
+	AuthWrappedToken
+	// AuthCustom is the authenticator given with WithAuthenticator.
+	AuthCustom
+	// AuthPassword is userpass or LDAP authentication configured with WithUserpass or WithLDAP.
+	AuthPassword
+	// AuthKubernetes is Kubernetes authentication configured with MOUNT_PATH and ROLE or WithKubernetes.
+	AuthKubernetes
+	// AuthOIDC is OIDC authentication, headless if VAULT_OIDC_HEADLESS or WithOIDCHeadless is set. It needs no other
+	// configuration than the address of Vault.
+	AuthOIDC
+	// AuthGitHub is GitHub authentication configured with GITHUB_TOKEN or WithGitHubToken.
+	AuthGitHub
+)
+
+func (m AuthMethod) String() string {
+	switch m {
+	case AuthToken:
+		return "token"
+	case AuthTokenFile:
+		return "token file"
+	case AuthWrappedToken:
+		return "wrapped token"
+	case AuthCustom:
+		return "custom authenticator"
+	case AuthPassword:
+		return "password"
+	case AuthKubernetes:
+		return "kubernetes"
+	case AuthOIDC:
+		return "oidc"
+	case AuthGitHub:
+		return "github"
+	default:
+		return fmt.Sprintf("AuthMethod(%d)", int(m))
+	}
+}
+
+// WithAuthChain sets the authentication methods to try, in order, instead of the single method picked by the fixed
+// precedence. Methods that are not configured are skipped, and the first method that succeeds is used for the lifetime
+// of the SecretsManager. If all methods fail, the error explains for each method why it was tried or skipped.
+//
+// For instance, WithAuthChain(AuthKubernetes, AuthTokenFile, AuthOIDC) uses Kubernetes authentication in the cluster,
+// where MOUNT_PATH and ROLE are set, the token file of a Vault Agent where VAULT_TOKEN_FILE is set, and OIDC on a
+// development machine. The token in ~/.vault-token is not used with an auth chain.
+func WithAuthChain(methods ...AuthMethod) Option {
+	return func(o *optionsCollector) {
+		o.authChain = methods
+	}
+}
+
+// chainLink is a method in the auth chain, resolved against the configuration.
+type chainLink struct {
+	method        AuthMethod
+	authMethod    auth.Method
+	authenticator Authenticator
+
+	// source describes where the configuration of the method came from, or why the method is skipped if it is not
+	// configured.
+	source     string
+	configured bool
+}
+
+// chainLinks resolves the methods of the auth chain against the options and environment variables.
+func (c *optionsCollector) chainLinks() []chainLink {
+	links := make([]chainLink, 0, len(c.authChain))
+	for _, m := range c.authChain {
+		link := chainLink{method: m}
+		switch m {
+		case AuthToken:
+			link.authMethod = auth.MethodCustom
+			link.authenticator = tokenAuthenticator{token: c.vaultToken}
+			link.configured = c.vaultToken != ""
+			link.source = c.source(link.configured, "WithVaultToken", "VAULT_TOKEN")
+		case AuthTokenFile:
+			link.authMethod = auth.MethodCustom
+			link.authenticator = tokenFileAuthenticator{path: c.vaultTokenFile}
+			link.configured = c.vaultTokenFile != ""
+			link.source = c.source(link.configured, "WithVaultTokenFile", "VAULT_TOKEN_FILE")
+		case AuthWrappedToken:
+			link.authMethod = auth.MethodWrappedToken
+			link.configured = c.wrappedToken != ""
+			link.source = c.source(link.configured, "WithWrappedToken or WithWrappedTokenFile", "VAULT_WRAPPED_TOKEN", "VAULT_WRAPPED_TOKEN_FILE")
+		case AuthCustom:
+			link.authMethod = auth.MethodCustom
+			link.authenticator = c.authenticator
+			link.configured = c.authenticator != nil
+			link.source = c.source(link.configured, "WithAuthenticator")
+		case AuthPassword:
+			link.authMethod = c.passwordMethod
+			link.configured = c.passwordMethod != 0
+			link.source = c.source(link.configured, "WithUserpass or WithLDAP")
+		case AuthKubernetes:
+			link.authMethod = auth.MethodK8s
+			link.configured = c.k8sMountPath != "" && c.k8sRole != ""
+			link.source = c.source(link.configured, "WithKubernetes", "MOUNT_PATH", "ROLE")
+		case AuthOIDC:
+			link.authMethod = auth.MethodOICD
+			if c.oidcHeadless {
+				link.authMethod = auth.MethodOICDHeadless
+			}
+			link.configured = true
+			link.source = c.source(link.configured, "WithAuthChain", "VAULT_OIDC_HEADLESS")
+		case AuthGitHub:
+			link.authMethod = auth.MethodGitHub
+			link.configured = c.gitHubToken != ""
+			link.source = c.source(link.configured, "WithGitHubToken", "GITHUB_TOKEN")
+		default:
+			link.source = "unknown authentication method"
+		}
+		links = append(links, link)
+	}
+
+	return links
+}
+
+// source describes where the configuration of a method came from: the environment variables among names that were
+// seen, or otherwise the option. If the method is not configured, it describes what was missing instead.
+func (c *optionsCollector) source(configured bool, option string, names ...string) string {
+	var seen []string
+	for _, name := range names {
+		for _, env := range c.envSeen {
+			if env == name {
+				seen = append(seen, name)
+			}
+		}
+	}
+
+	switch {
+	case configured && len(seen) > 0:
+		return "from " + strings.Join(seen, " and ")
+	case configured:
+		return "from " + option
+	case len(names) == 1:
+		return fmt.Sprintf("%s not set and %s not given", names[0], option)
+	case len(names) > 1:
+		return fmt.Sprintf("%s not all set and %s not given", strings.Join(names, " and "), option)
+	default:
+		return option + " not given"
+	}
+}
+
+// authenticateChain tries the methods of the auth chain in order. The first method that succeeds is used from then on,
+// also when the token is renewed. If all methods fail, the returned error has a line for each method.
+func (j *tokenJob) authenticateChain(ctx context.Context) (auth.AuthenticationResponse, error) {
+	seen := "none"
+	if len(j.chainEnv) > 0 {
+		seen = strings.Join(j.chainEnv, ", ")
+	}
+	errs := []error{fmt.Errorf("no authentication method in the auth chain succeeded (environment variables seen: %s)", seen)}
+
+	for _, link := range j.chain {
+		if !link.configured {
+			j.l.Printf("skipping %s in auth chain: %s", link.method, link.source)
+			errs = append(errs, fmt.Errorf("%s: skipped, %s", link.method, link.source))
+			continue
+		}
+
+		j.l.Printf("trying %s in auth chain (%s)", link.method, link.source)
+		j.method = link.authMethod
+		if link.authenticator != nil {
+			j.authenticator = link.authenticator
+		}
+		ar, err := auth.Authenticate(ctx, j.vaultAddress, j.method, j.options()...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s (%s): %w", link.method, link.source, err))
+			continue
+		}
+
+		j.l.Printf("authenticated using %s in auth chain", link.method)
+		if a, ok := link.authenticator.(tokenFileAuthenticator); ok {
+			fj, err := newTokenFileJob(a.path, j.l)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s (%s): %w", link.method, link.source, err))
+				continue
+			}
+			j.tokenFile = fj
+		}
+		j.chain = nil
+		return ar, nil
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+// tokenAuthenticator checks that a static Vault token is valid. The token is not renewed.
+type tokenAuthenticator struct {
+	token string
+}
+
+func (a tokenAuthenticator) Login(ctx context.Context, c *AuthClient) (AuthResult, error) {
+	if _, err := auth.LookupSelf(ctx, c, a.token); err != nil {
+		return AuthResult{}, fmt.Errorf("vault token is not valid: %w", err)
+	}
+	return AuthResult{Token: a.token}, nil
+}
+
+// tokenFileAuthenticator reads a Vault token from a file and checks that it is valid. The token has no lease of its
+// own, since it is renewed by the process maintaining the file. Once the auth chain has picked the file, the token job
+// re-reads it whenever it is modified, like a token file configured without an auth chain.
+type tokenFileAuthenticator struct {
+	path string
+}
+
+func (a tokenFileAuthenticator) Login(ctx context.Context, c *AuthClient) (AuthResult, error) {
+	b, err := os.ReadFile(a.path)
+	if err != nil {
+		return AuthResult{}, fmt.Errorf("while reading token file: %w", err)
+	}
+	token := strings.TrimSpace(string(b))
+	if _, err := auth.LookupSelf(ctx, c, token); err != nil {
+		return AuthResult{}, fmt.Errorf("vault token is not valid: %w", err)
+	}
+	return AuthResult{Token: token}, nil
+}
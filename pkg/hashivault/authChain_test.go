@@ -0,0 +1,142 @@
+package hashivault
+
+import (
+	"context"
+	"github.com/3lvia/hashivault-go/internal/auth"
+	"github.com/3lvia/hashivault-go/internal/logging"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_tokenJob_authenticateChain(t *testing.T) {
+	tests := []struct {
+		name        string
+		gitHubToken string
+		wantMethod  auth.Method
+		wantErr     []string
+	}{
+		{
+			name:        "falls back to github",
+			gitHubToken: "my-github-token",
+			wantMethod:  auth.MethodGitHub,
+		},
+		{
+			name: "all methods fail",
+			wantErr: []string{
+				"environment variables seen: VAULT_ADDR, VAULT_TOKEN_FILE",
+				"kubernetes: skipped, MOUNT_PATH and ROLE not all set and WithKubernetes not given",
+				"token file (from VAULT_TOKEN_FILE): vault token is not valid: unexpected status code: 403: permission denied",
+				"github: skipped, GITHUB_TOKEN not set and WithGitHubToken not given",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnvVars(t)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v1/auth/token/lookup-self":
+					w.WriteHeader(http.StatusForbidden)
+					w.Write([]byte(`{"errors":["permission denied"]}`))
+				case "/v1/auth/github/login":
+					w.Write([]byte(`{"auth":{"client_token":"github-vault-token","lease_duration":3600,"renewable":true}}`))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			path := filepath.Join(t.TempDir(), "sink")
+			NoErr(t, os.WriteFile(path, []byte("expired-token\n"), 0600))
+			t.Setenv("VAULT_ADDR", server.URL)
+			t.Setenv("VAULT_TOKEN_FILE", path)
+
+			c := &optionsCollector{}
+			for _, opt := range []Option{
+				WithGitHubToken(tt.gitHubToken),
+				WithAuthChain(AuthKubernetes, AuthTokenFile, AuthGitHub),
+			} {
+				opt(c)
+			}
+			NoErr(t, c.build())
+
+			j := newTokenJob(c, server.Client(), logging.New(nil, nil))
+			ar, err := j.authenticate(context.Background())
+			if len(tt.wantErr) > 0 {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				for _, want := range tt.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("expected error to contain %q, got: %s", want, err)
+					}
+				}
+				return
+			}
+
+			NoErr(t, err)
+			if ar.ClientToken() != "github-vault-token" {
+				t.Errorf("unexpected token, got: %s", ar.ClientToken())
+			}
+			if j.method != tt.wantMethod {
+				t.Errorf("unexpected method, got: %d", j.method)
+			}
+		})
+	}
+}
+
+func Test_tokenJob_authenticateChain_tokenFile(t *testing.T) {
+	clearEnvVars(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/auth/token/lookup-self" || r.Header.Get("X-Vault-Token") != "token-1" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"data":{"ttl":3600}}`))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "sink")
+	NoErr(t, os.WriteFile(path, []byte("token-1\n"), 0600))
+
+	c := &optionsCollector{}
+	for _, opt := range []Option{
+		WithVaultAddress(server.URL),
+		WithVaultTokenFile(path),
+		WithAuthChain(AuthTokenFile),
+	} {
+		opt(c)
+	}
+	NoErr(t, c.build())
+
+	j := newTokenJob(c, server.Client(), logging.New(nil, nil))
+	ar, err := j.authenticate(context.Background())
+	NoErr(t, err)
+
+	// the token is renewed by the process maintaining the file, so it has no lease that the token job would renew
+	if ar.LeaseDurationSeconds() != 0 || ar.Renewable() {
+		t.Errorf("expected no lease, got %ds, renewable %v", ar.LeaseDurationSeconds(), ar.Renewable())
+	}
+	if expires, _ := j.schedule(ar); !expires.IsZero() {
+		t.Errorf("expected a token without expiry, got: %s", expires)
+	}
+	if j.tokenFile == nil || j.token() != "token-1" {
+		t.Fatalf("expected the token to be served from the file, got: %s", j.token())
+	}
+
+	// make sure the modification time changes on file systems with coarse timestamps
+	NoErr(t, os.WriteFile(path, []byte("token-2\n"), 0600))
+	NoErr(t, os.Chtimes(path, time.Now().Add(time.Second), time.Now().Add(time.Second)))
+	_, err = j.tokenFile.read()
+	NoErr(t, err)
+	if j.token() != "token-2" {
+		t.Errorf("expected the replaced token, got: %s", j.token())
+	}
+}
@@ -0,0 +1,33 @@
+package hashivault
+
+import "github.com/3lvia/hashivault-go/internal/auth"
+
+// Authenticator logs in to Vault. The built-in authentication methods (GitHub, Kubernetes and OIDC) are implemented as
+// authenticators, and other methods, such as userpass or LDAP, can be added by implementing Authenticator and passing
+// it to WithAuthenticator. The Login method is given an AuthClient, which has the address of Vault and the http client
+// to use, and a helper for the common case of writing credentials to a login endpoint:
+//
+//	type userpass struct{ user, password string }
+//
+//	func (u userpass) Login(ctx context.Context, c *hashivault.AuthClient) (hashivault.AuthResult, error) {
+//		return c.Login(ctx, "auth/userpass/login/"+u.user, map[string]string{"password": u.password})
+//	}
+type Authenticator = auth.Authenticator
+
+// AuthRenewer is implemented by authenticators that can renew the token when it is about to expire. Tokens from
+// authenticators that do not implement it are replaced by logging in again.
+type AuthRenewer = auth.Renewer
+
+// AuthResult is the token obtained by an Authenticator.
+type AuthResult = auth.AuthResult
+
+// AuthClient is given to authenticators to make requests to Vault.
+type AuthClient = auth.Client
+
+// WithAuthenticator sets the authenticator to use when authenticating to Vault. It takes precedence over the built-in
+// authentication methods, except Vault tokens and wrapped tokens.
+func WithAuthenticator(a Authenticator) Option {
+	return func(o *optionsCollector) {
+		o.authenticator = a
+	}
+}
@@ -0,0 +1,148 @@
+package hashivault
+
+import (
+	"context"
+	"fmt"
+	"github.com/3lvia/hashivault-go/internal/logging"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"sync"
+	"time"
+)
+
+// awsExpiryWindow is how long before the credentials expire their lease is renewed, or new credentials are issued.
+const awsExpiryWindow = 5 * time.Minute
+
+func (m *manager) AWSCredentialsProvider(ctx context.Context, path string) (aws.CredentialsProvider, error) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(
+		ctx,
+		"hashivault.AWSCredentialsProvider",
+		trace.WithAttributes(attribute.String("path", path)))
+	defer span.End()
+
+	m.l.With(logging.Path(path)).Printf("getting aws credentials provider from %s", path)
+
+	cache := aws.NewCredentialsCache(&awsCredentialsProvider{m: m, path: path}, func(o *aws.CredentialsCacheOptions) {
+		o.ExpiryWindow = awsExpiryWindow
+		o.ExpiryWindowJitterFrac = 0.5
+	})
+
+	// Fetch the first credentials right away, so that configuration errors are reported here rather than on the first
+	// request to AWS.
+	if _, err := cache.Retrieve(spanCtx); err != nil {
+		traceError(span, err, m.l)
+		return nil, err
+	}
+
+	return cache, nil
+}
+
+// awsCredentialsProvider renews the lease of the current credentials every time Retrieve is called, and issues new
+// credentials from the AWS secrets engine once the lease has reached its max TTL or cannot be renewed. It is wrapped in
+// aws.CredentialsCache, so Retrieve is only called when the current credentials are about to expire. The lease of
+// replaced credentials is revoked a minute later, so that e.g. the IAM user behind them is deleted once the requests
+// signed with them have completed.
+type awsCredentialsProvider struct {
+	m    *manager
+	path string
+
+	// creds are the current credentials, and ttl is the lease duration they were issued with, which is requested when
+	// the lease is renewed.
+	mux       sync.Mutex
+	creds     aws.Credentials
+	leaseID   string
+	renewable bool
+	ttl       int
+}
+
+func (p *awsCredentialsProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(
+		ctx,
+		"hashivault.awsCredentialsProvider.Retrieve",
+		trace.WithAttributes(attribute.String("path", p.path)))
+	defer span.End()
+
+	if creds, ok := p.renew(spanCtx); ok {
+		return creds, nil
+	}
+
+	sec, err := p.m.read(spanCtx, p.path)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+
+	d := sec.data()
+	accessKey, _ := d["access_key"].(string)
+	secretKey, _ := d["secret_key"].(string)
+	if accessKey == "" || secretKey == "" {
+		err := fmt.Errorf("no aws credentials found in secret %s", p.path)
+		traceError(span, err, p.m.l)
+		return aws.Credentials{}, err
+	}
+
+	// STS credentials carry a session token, which older versions of Vault return as security_token.
+	sessionToken, _ := d["session_token"].(string)
+	if sessionToken == "" {
+		sessionToken, _ = d["security_token"].(string)
+	}
+
+	creds := aws.Credentials{
+		AccessKeyID:     accessKey,
+		SecretAccessKey: secretKey,
+		SessionToken:    sessionToken,
+		Source:          "hashivault",
+	}
+	if sec.LeaseDuration > 0 {
+		creds.CanExpire = true
+		creds.Expires = time.Now().Add(time.Duration(sec.LeaseDuration) * time.Second)
+	}
+
+	p.m.trackLease(sec.LeaseID)
+	p.mux.Lock()
+	replaced := p.leaseID
+	p.creds = creds
+	p.leaseID = sec.LeaseID
+	p.renewable = sec.Renewable
+	p.ttl = sec.LeaseDuration
+	p.mux.Unlock()
+	p.m.retireLease(ComponentAWS, p.path, replaced)
+
+	p.m.l.Printf("got aws credentials from %s with lease duration %d", p.path, sec.LeaseDuration)
+	return creds, nil
+}
+
+// renew renews the lease of the current credentials and returns them with the new expiry. It returns false if there
+// are no current credentials, or if their lease cannot be renewed or has reached its max TTL, in which case new
+// credentials must be issued.
+func (p *awsCredentialsProvider) renew(ctx context.Context) (aws.Credentials, bool) {
+	p.mux.Lock()
+	creds, leaseID, renewable, ttl := p.creds, p.leaseID, p.renewable, p.ttl
+	p.mux.Unlock()
+	if leaseID == "" || !renewable || ttl <= 0 {
+		return aws.Credentials{}, false
+	}
+
+	lease, err := renewLease(ctx, leaseID, ttl, p.m.vaultAddress, p.m.tokenGetter(), p.m.client, p.m.l)
+	if err != nil {
+		p.m.l.With(logging.Path(p.path), logging.Error(err)).
+			Errorf("renewing the lease of the aws credentials from %s failed, issuing new ones: %v", p.path, err)
+		return aws.Credentials{}, false
+	}
+	if !lease.Renewable || lease.LeaseDuration < ttl {
+		p.m.l.With(logging.Path(p.path), logging.LeaseDuration(time.Duration(lease.LeaseDuration)*time.Second)).
+			Printf("lease of the aws credentials from %s reached its max TTL, issuing new ones", p.path)
+		return aws.Credentials{}, false
+	}
+
+	creds.Expires = time.Now().Add(time.Duration(lease.LeaseDuration) * time.Second)
+	p.mux.Lock()
+	p.creds = creds
+	p.mux.Unlock()
+
+	p.m.l.Printf("renewed aws credentials from %s with lease duration %d", p.path, lease.LeaseDuration)
+	return creds, true
+}
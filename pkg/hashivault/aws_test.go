@@ -0,0 +1,206 @@
+package hashivault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_manager_AWSCredentialsProvider(t *testing.T) {
+	tests := []struct {
+		name             string
+		path             string
+		data             map[string]any
+		leaseDuration    int
+		wantSessionToken string
+		wantErr          bool
+	}{
+		{
+			name:          "iam user",
+			path:          "aws/creds/etl",
+			data:          map[string]any{"access_key": "AKIA1", "secret_key": "secret", "security_token": nil},
+			leaseDuration: 2764800,
+		},
+		{
+			name: "sts",
+			path: "aws/sts/etl",
+			data: map[string]any{
+				"access_key":     "ASIA1",
+				"secret_key":     "secret",
+				"security_token": "session",
+				"session_token":  "session",
+			},
+			leaseDuration:    3599,
+			wantSessionToken: "session",
+		},
+		{
+			name:    "no credentials",
+			path:    "aws/creds/etl",
+			data:    map[string]any{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mux sync.Mutex
+			issued := 0
+			m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/"+tt.path {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				mux.Lock()
+				issued++
+				mux.Unlock()
+				json.NewEncoder(w).Encode(map[string]any{
+					"lease_id":       tt.path + "/abc",
+					"renewable":      true,
+					"lease_duration": tt.leaseDuration,
+					"data":           tt.data,
+				})
+			})
+
+			p, err := m.AWSCredentialsProvider(context.Background(), tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AWSCredentialsProvider() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			creds, err := p.Retrieve(context.Background())
+			NoErr(t, err)
+
+			if creds.AccessKeyID == "" || creds.SecretAccessKey != "secret" {
+				t.Errorf("unexpected credentials, got: %+v", creds)
+			}
+			if creds.SessionToken != tt.wantSessionToken {
+				t.Errorf("unexpected session token, got: %s", creds.SessionToken)
+			}
+			if !creds.CanExpire || time.Until(creds.Expires) > time.Duration(tt.leaseDuration)*time.Second {
+				t.Errorf("unexpected expiry, got: %s", creds.Expires)
+			}
+			mux.Lock()
+			defer mux.Unlock()
+			if issued != 1 {
+				t.Errorf("expected the credentials to be cached, got %d issued", issued)
+			}
+		})
+	}
+}
+
+func Test_awsCredentialsProvider_renew(t *testing.T) {
+	var mux sync.Mutex
+	issued := 0
+	granted := []int{3600, 1800}
+	revoked := make(chan string, 2)
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		switch r.URL.Path {
+		case "/v1/aws/creds/etl":
+			issued++
+			json.NewEncoder(w).Encode(map[string]any{
+				"lease_id":       fmt.Sprintf("aws/creds/etl/%d", issued),
+				"renewable":      true,
+				"lease_duration": 3600,
+				"data":           map[string]any{"access_key": fmt.Sprintf("AKIA%d", issued), "secret_key": "secret"},
+			})
+		case "/v1/sys/leases/renew":
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			json.NewEncoder(w).Encode(map[string]any{
+				"lease_id":       body["lease_id"],
+				"renewable":      true,
+				"lease_duration": granted[0],
+			})
+			granted = granted[1:]
+		case "/v1/sys/leases/revoke":
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			revoked <- body["lease_id"].(string)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	m.revokeDelay = 0
+
+	// the cache decides when to call the provider, which renews the lease until it reaches its max TTL
+	p := &awsCredentialsProvider{m: m, path: "aws/creds/etl"}
+	var keys []string
+	for i := 0; i < 3; i++ {
+		creds, err := p.Retrieve(context.Background())
+		NoErr(t, err)
+		keys = append(keys, creds.AccessKeyID)
+	}
+
+	if want := []string{"AKIA1", "AKIA1", "AKIA2"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("expected new credentials only at the max TTL, got: %v, want: %v", keys, want)
+	}
+	select {
+	case leaseID := <-revoked:
+		if leaseID != "aws/creds/etl/1" {
+			t.Errorf("expected the replaced credentials to be revoked, got: %s", leaseID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the replaced credentials to be revoked")
+	}
+}
+
+func Test_awsCredentialsProvider_revoke(t *testing.T) {
+	var mux sync.Mutex
+	var revoked []string
+	issued := 0
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		switch r.URL.Path {
+		case "/v1/aws/creds/etl":
+			issued++
+			json.NewEncoder(w).Encode(map[string]any{
+				"lease_id":       fmt.Sprintf("aws/creds/etl/%d", issued),
+				"renewable":      false,
+				"lease_duration": 3600,
+				"data":           map[string]any{"access_key": "AKIA1", "secret_key": "secret"},
+			})
+		case "/v1/sys/leases/revoke":
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			revoked = append(revoked, body["lease_id"].(string))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	m.revokeOnClose = true
+
+	// the lease cannot be renewed, so every call issues new credentials, and the replaced ones are revoked later
+	m.revokeDelay = time.Hour
+	p := &awsCredentialsProvider{m: m, path: "aws/creds/etl"}
+	for i := 0; i < 2; i++ {
+		_, err := p.Retrieve(context.Background())
+		NoErr(t, err)
+	}
+
+	mux.Lock()
+	if len(revoked) != 0 {
+		t.Errorf("expected the replaced credentials to be revoked later, got: %v", revoked)
+	}
+	mux.Unlock()
+
+	NoErr(t, m.Close())
+	mux.Lock()
+	defer mux.Unlock()
+	sort.Strings(revoked)
+	if !reflect.DeepEqual(revoked, []string{"aws/creds/etl/1", "aws/creds/etl/2"}) {
+		t.Errorf("expected the replaced and current credentials to be revoked on Close, got: %v", revoked)
+	}
+}
@@ -0,0 +1,241 @@
+package hashivault
+
+import (
+	"context"
+	"fmt"
+	"github.com/3lvia/hashivault-go/internal/logging"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAzureAuthorityHost      = "https://login.microsoftonline.com/"
+	defaultAzurePropagationTimeout = 2 * time.Minute
+
+	// azureRetryInterval is the initial interval between token requests while waiting for new credentials to
+	// propagate in Azure AD. The interval is doubled for every attempt, up to azureMaxRetryInterval.
+	azureRetryInterval    = 500 * time.Millisecond
+	azureMaxRetryInterval = 10 * time.Second
+)
+
+// azurePropagationErrors are the Azure AD error codes returned for service principals and secrets that have not yet
+// been replicated, i.e. "application not found" and "invalid client secret".
+var azurePropagationErrors = []string{"AADSTS700016", "AADSTS7000215"}
+
+// AzureOption configures an AzureCredential.
+type AzureOption func(*AzureCredential)
+
+// WithAzureAuthorityHost sets the Azure AD authority host, for use with national clouds. The default is
+// "https://login.microsoftonline.com/".
+func WithAzureAuthorityHost(host string) AzureOption {
+	return func(c *AzureCredential) {
+		c.authorityHost = strings.TrimSuffix(host, "/") + "/"
+	}
+}
+
+// WithAzurePropagationTimeout sets for how long after new credentials have been issued failed token requests are
+// retried, while waiting for the credentials to be replicated in Azure AD. The default is two minutes.
+func WithAzurePropagationTimeout(d time.Duration) AzureOption {
+	return func(c *AzureCredential) {
+		c.propagationTimeout = d
+	}
+}
+
+// WithAzureProbe makes AzureCredential wait until a token can be issued for the given scope, e.g.
+// "https://management.azure.com/.default", before returning. The credentials are probed the same way every time they
+// are rotated.
+func WithAzureProbe(scope string) AzureOption {
+	return func(c *AzureCredential) {
+		c.probeScope = scope
+	}
+}
+
+// AzureCredential is a dynamic service principal issued by the Azure secrets engine. The client ID and secret are
+// kept up to date as the lease of the service principal expires, and AzureCredential implements
+// azcore.TokenCredential, so it can be given directly to the Azure SDK clients. It is safe to use concurrently.
+type AzureCredential struct {
+	m        *manager
+	path     string
+	tenantID string
+
+	authorityHost      string
+	propagationTimeout time.Duration
+	probeScope         string
+
+	secrets EvergreenSecretsFunc
+	mux     *sync.Mutex
+	issued  time.Time
+}
+
+func (m *manager) AzureCredential(ctx context.Context, path, tenantID string, opts ...AzureOption) (*AzureCredential, error) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(
+		ctx,
+		"hashivault.AzureCredential",
+		trace.WithAttributes(attribute.String("path", path)))
+	defer span.End()
+
+	m.l.With(logging.Path(path)).Printf("getting azure credential from %s", path)
+
+	c := &AzureCredential{
+		m:                  m,
+		path:               path,
+		tenantID:           tenantID,
+		authorityHost:      defaultAzureAuthorityHost,
+		propagationTimeout: defaultAzurePropagationTimeout,
+		mux:                &sync.Mutex{},
+		issued:             time.Now(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	sec, es, err := m.getSecret(spanCtx, path, m.renewal)
+	if err != nil {
+		return nil, err
+	}
+	c.secrets = sec.data
+	if es != nil {
+		c.secrets = es.get
+		changed := make(chan struct{}, 1)
+		es.subscribe(changed)
+		go c.start(changed)
+	}
+
+	if c.ClientID() == "" || c.ClientSecret() == "" {
+		err := fmt.Errorf("no azure credentials found in secret %s", path)
+		traceError(span, err, m.l)
+		return nil, err
+	}
+
+	if c.probeScope != "" {
+		if err := c.probe(spanCtx); err != nil {
+			traceError(span, err, m.l)
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// ClientID returns the client ID of the current service principal.
+func (c *AzureCredential) ClientID() string {
+	id, _ := c.secrets()["client_id"].(string)
+	return id
+}
+
+// ClientSecret returns the client secret of the current service principal.
+func (c *AzureCredential) ClientSecret() string {
+	s, _ := c.secrets()["client_secret"].(string)
+	return s
+}
+
+// GetToken implements azcore.TokenCredential. If the credentials have been issued recently and Azure AD rejects them
+// because they have not been replicated yet, the request is retried until the propagation timeout has passed or ctx
+// is done.
+func (c *AzureCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(
+		ctx,
+		"hashivault.AzureCredential.GetToken",
+		trace.WithAttributes(attribute.StringSlice("scopes", opts.Scopes)))
+	defer span.End()
+
+	tenantID := opts.TenantID
+	if tenantID == "" {
+		tenantID = c.tenantID
+	}
+
+	interval := azureRetryInterval
+	for {
+		t, err := c.token(spanCtx, tenantID, opts.Scopes)
+		if err == nil {
+			return azcore.AccessToken{Token: t.AccessToken, ExpiresOn: t.Expiry}, nil
+		}
+
+		c.mux.Lock()
+		deadline := c.issued.Add(c.propagationTimeout)
+		c.mux.Unlock()
+		if !isAzurePropagationError(err) || time.Now().Add(interval).After(deadline) {
+			traceError(span, err, c.m.l)
+			return azcore.AccessToken{}, err
+		}
+
+		c.m.l.Printf("azure credentials from %s not yet propagated, retrying in %s", c.path, interval)
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return azcore.AccessToken{}, ctx.Err()
+		}
+		interval *= 2
+		if interval > azureMaxRetryInterval {
+			interval = azureMaxRetryInterval
+		}
+	}
+}
+
+// token exchanges the current client ID and secret for an access token using the client credentials flow.
+func (c *AzureCredential) token(ctx context.Context, tenantID string, scopes []string) (*oauth2.Token, error) {
+	secrets := c.secrets()
+	clientID, _ := secrets["client_id"].(string)
+	clientSecret, _ := secrets["client_secret"].(string)
+
+	cfg := clientcredentials.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		TokenURL:     c.authorityHost + tenantID + "/oauth2/v2.0/token",
+		Scopes:       scopes,
+		AuthStyle:    oauth2.AuthStyleInParams,
+	}
+	return cfg.Token(context.WithValue(ctx, oauth2.HTTPClient, c.m.client))
+}
+
+func (c *AzureCredential) probe(ctx context.Context) error {
+	if _, err := c.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{c.probeScope}}); err != nil {
+		return fmt.Errorf("while probing azure credentials from %s: %w", c.path, err)
+	}
+	c.m.l.Printf("azure credentials from %s are ready", c.path)
+	return nil
+}
+
+// start records when the credentials are rotated, and probes the new credentials if configured, until the manager is
+// closed.
+func (c *AzureCredential) start(changed <-chan struct{}) {
+	for {
+		select {
+		case <-c.m.ctx.Done():
+			return
+		case <-changed:
+		}
+
+		c.m.l.Printf("azure credentials from %s rotated", c.path)
+		c.mux.Lock()
+		c.issued = time.Now()
+		c.mux.Unlock()
+
+		if c.probeScope != "" {
+			if err := c.probe(c.m.ctx); err != nil {
+				c.m.events.report(ComponentAzure, c.path, err)
+				continue
+			}
+			c.m.events.resolved(ComponentAzure, c.path)
+		}
+	}
+}
+
+func isAzurePropagationError(err error) bool {
+	for _, code := range azurePropagationErrors {
+		if strings.Contains(err.Error(), code) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package hashivault
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func Test_manager_AzureCredential(t *testing.T) {
+	tokenRequests := 0
+	countMux := &sync.Mutex{}
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/azure/creds/my-role":
+			json.NewEncoder(w).Encode(map[string]any{
+				"lease_id":       "azure/creds/my-role/abc",
+				"renewable":      true,
+				"lease_duration": 3600,
+				"data":           map[string]any{"client_id": "my-client-id", "client_secret": "my-client-secret"},
+			})
+		case "/my-tenant/oauth2/v2.0/token":
+			countMux.Lock()
+			tokenRequests++
+			n := tokenRequests
+			countMux.Unlock()
+
+			if r.FormValue("client_id") != "my-client-id" || r.FormValue("client_secret") != "my-client-secret" {
+				t.Errorf("unexpected client credentials: %s", r.Form)
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			// the first request fails as if the service principal has not been replicated yet
+			if n == 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]any{
+					"error":             "unauthorized_client",
+					"error_description": "AADSTS700016: Application with identifier 'my-client-id' was not found.",
+				})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"access_token": "my-access-token",
+				"token_type":   "Bearer",
+				"expires_in":   3599,
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	cred, err := m.AzureCredential(
+		context.Background(),
+		"azure/creds/my-role",
+		"my-tenant",
+		WithAzureAuthorityHost(m.vaultAddress),
+		WithAzureProbe("https://management.azure.com/.default"))
+	NoErr(t, err)
+
+	if cred.ClientID() != "my-client-id" || cred.ClientSecret() != "my-client-secret" {
+		t.Errorf("unexpected client credentials, got: %s/%s", cred.ClientID(), cred.ClientSecret())
+	}
+
+	tok, err := cred.GetToken(context.Background(), policy.TokenRequestOptions{
+		Scopes: []string{"https://storage.azure.com/.default"},
+	})
+	NoErr(t, err)
+	if tok.Token != "my-access-token" {
+		t.Errorf("unexpected access token, got: %s", tok.Token)
+	}
+
+	if tokenRequests != 3 {
+		t.Errorf("expected 3 token requests (failed probe, probe and token), got %d", tokenRequests)
+	}
+}
+
+func Test_manager_AzureCredential_propagationTimeout(t *testing.T) {
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/azure/creds/my-role":
+			json.NewEncoder(w).Encode(map[string]any{
+				"data": map[string]any{"client_id": "my-client-id", "client_secret": "my-client-secret"},
+			})
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]any{
+				"error":             "invalid_client",
+				"error_description": "AADSTS7000215: Invalid client secret provided.",
+			})
+		}
+	})
+
+	_, err := m.AzureCredential(
+		context.Background(),
+		"azure/creds/my-role",
+		"my-tenant",
+		WithAzureAuthorityHost(m.vaultAddress),
+		WithAzurePropagationTimeout(0),
+		WithAzureProbe("https://management.azure.com/.default"))
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
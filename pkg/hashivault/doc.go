@@ -2,11 +2,17 @@
 Package hashivault provides a Vault client for the Hashicorp Vault secrets management solution.
 
 AUTHENTICATION
-Four modes of authentication against Vault are supported(here listed according to precedence):
-1. Vault tokens (for people), usually in debugging situations where the other methods are not available
-2. Kubernetes authentication for pods
-3. Azure AD SSO authentication (OICD) for people
-4. GitHub authentication for people
+Six modes of authentication against Vault are supported(here listed according to precedence):
+1. Vault tokens, given directly or read from a file maintained by another process, such as a Vault Agent sidecar
+2. Response-wrapped tokens, delivered to services by an orchestrator as a single-use bootstrap secret
+3. Username and password authentication (userpass or LDAP) for people, optionally with MFA
+4. Kubernetes authentication for pods
+5. Azure AD SSO authentication (OICD) for people
+6. GitHub authentication for people
+
+Other auth methods, such as userpass or LDAP, can be used by implementing the Authenticator interface and passing it
+to WithAuthenticator. A custom authenticator takes precedence over username and password, Kubernetes, OIDC and GitHub
+authentication.
 
 The package can be configured via the options pattern, i.e. by sending a number of options to the New function.
 However, environment variables can also be used to configure this package. Configuration via environment variables
@@ -14,9 +20,23 @@ takes precedence over configuration via the options pattern. The following envir
  1. GITHUB_TOKEN. If this variable is set, the client will authenticate against Vault using the GitHub auth method.
     This takes precedence over the other methods, except pre-authentication, se 4) below.
  2. MOUNT_PATH and ROLE. If these variables are set, the client will authenticate using the Kubernetes auth method.
+    The service account token is read from /var/run/secrets/kubernetes.io/serviceaccount/token, or from the file in
+    VAULT_K8S_TOKEN_PATH, e.g. a projected token. If VAULT_K8S_AUDIENCE is set, the token must be issued for it.
  3. VAULT_ADDR. This variable must be set to the address of the Vault server.
  4. VAULT_TOKEN. If this variable is set, the client will be pre-authenticated, and will use the supplied token for
     all requests to Vault. This takes precedence over the other methods.
+ 5. VAULT_WRAPPED_TOKEN or VAULT_WRAPPED_TOKEN_FILE. If one of these variables is set, the client will unwrap the
+    response-wrapping token (or the token in the file) and use the resulting Vault token. As the wrapping token can only
+    be used once, the unwrapped token is renewed rather than unwrapped again. This takes precedence over the other
+    methods, except pre-authentication, see 4) above.
+ 6. VAULT_TOKEN_FILE. If this variable is set, the client will use the token in the file, and re-read it whenever
+    the file changes. A response-wrapping token takes precedence over the file, see 5) above. If neither this nor any
+    other authentication method or auth chain is configured (except OIDC), the token written to ~/.vault-token by
+    "vault login" is used, provided that it is still valid.
+ 7. VAULT_OIDC_HEADLESS. If this variable is set to true, OIDC authentication is done without a callback server on
+    localhost, see WithOIDCHeadless below.
+ 8. VAULT_USERNAME and VAULT_PASSWORD. If these variables are set, they are used as the username and password with
+    the userpass or LDAP auth method selected by WithUserpass or WithLDAP.
 
 OPTIONS
 The following options are supported:
@@ -33,6 +53,28 @@ The following options are supported:
     spans. If no name is set the tracer name "go.opentelemetry.io/otel" is used.
  8. WithLogger. This option can be used to set the logger to use when logging. If no logger is set a noop logger is
     used.
+ 9. WithGoogleCredentialsDir. This option can be used to set the directory where SetDefaultGoogleCredentials writes
+    the credentials file. If not set, a new temporary directory is used.
+ 10. WithWrappedToken and WithWrappedTokenFile. These options can be used to set a response-wrapping token to unwrap
+    when authenticating to Vault.
+ 11. WithVaultTokenFile. This option can be used to set a file containing the Vault token, such as the sink file of a
+    Vault Agent.
+ 12. WithOIDCConfig and WithOIDCAuthURLHandler. These options can be used to configure the OIDC flow, see below.
+ 13. WithOIDCHeadless. This option can be used to set the authentication method to OIDC for remote machines and
+    devcontainers, where the browser cannot reach a callback server on localhost. The URL to log in at is printed, and
+    the user pastes the URL that the browser is redirected to after logging in (which fails to load) on stdin.
+ 14. WithoutOIDCTokenCache. This option can be used to disable caching of the token obtained with OIDC, see below.
+ 15. WithAuthenticator. This option can be used to authenticate with a custom Authenticator.
+ 16. WithAuthChain. This option can be used to try several authentication methods in order, see below.
+ 17. WithUserpass and WithLDAP. These options can be used to authenticate with a username and password, see below.
+ 18. WithMFAProvider. This option can be used to complete MFA when a login requires it, see below.
+ 19. WithKubernetesConfig. This option can be used to set the mount, role, token path and audience of Kubernetes
+    authentication. The token is read on each login, so projected tokens that are rotated by the kubelet are supported.
+ 20. WithErrorHandler. This option can be used to handle errors from the background jobs, see below.
+ 21. WithRenewalPolicy. This option can be used to set when the token, the leases of secrets and SSH certificates
+    are renewed, see below.
+ 22. WithRevokeOnClose. This option can be used to revoke the leases of secrets when the SecretsManager is closed.
+ 23. WithSlogLogger and WithLogrLogger. These options can be used to set a structured logger, see below.
 
 RECOMMENDED SETUP (ELVIA)
 In the context of developing and running services in Elvia, the recommended approach is to use OICD authentication
@@ -51,16 +93,95 @@ This ensures that the client will authenticate against Vault using OICD when run
 while it will use Kubernetes authentication when running in the Kubernetes cluster (because the environment
 variables MOUNT_PATH and ROLE will be set).
 
+With the fixed precedence above, a single authentication method is picked, and if it fails, the client fails. To make
+the setup more robust, an auth chain can be given with WithAuthChain. The methods in the chain are tried in order, and
+methods that are not configured are skipped. For instance, the following uses Kubernetes authentication in the cluster,
+and on the development machine the token from "vault login" if it is still valid, and OIDC otherwise:
+```
+
+	v, errChan, err := hashivault.New(ctx,
+		hashivault.WithAuthChain(hashivault.AuthKubernetes, hashivault.AuthTokenFile, hashivault.AuthOIDC),
+		hashivault.WithVaultAddress("https://vault.dev-elvia.io"))
+
+```
+If all methods in the chain fail, the error lists each method together with why it was skipped or how it failed, and
+which environment variables were seen.
+
+The token obtained with OICD is cached in the user config dir (e.g. ~/.config/hashivault on Linux) in a file that is
+only readable by the user, and it is reused on later starts for as long as Vault accepts it. Thus, the browser is only
+launched when the cached token has expired. If the file ~/.hashivault-key exists, its content is used as the key to
+encrypt the cached token.
+
+The OIDC flow can be configured with WithOIDCConfig, e.g. to log in with a non-default role, or to use another port for
+the callback server than the default 8250. With WithOIDCAuthURLHandler, the URL to complete the login at is given to a
+function rather than opened in the browser. The callback server only runs while the login is in progress, and the
+login fails if it is not completed within the timeout (five minutes by default):
+```
+
+	v, errChan, err := hashivault.New(ctx,
+		hashivault.WithOIDCConfig(hashivault.OIDCConfig{Role: "developer", Port: "8251"}),
+		hashivault.WithVaultAddress("https://vault.dev-elvia.io"))
+
+```
+
+Operators with LDAP accounts can log in with a username and password. If no password is given, it is obtained from
+the prompt, and if the login requires MFA, the passcode is obtained from the MFA provider. PromptPassword and
+PromptPasscode read from the terminal without echo:
+```
+
+	v, errChan, err := hashivault.New(ctx,
+		hashivault.WithLDAP(hashivault.PasswordConfig{
+			Username:       "jane",
+			PasswordPrompt: hashivault.PromptPassword,
+		}),
+		hashivault.WithMFAProvider(hashivault.PromptPasscode),
+		hashivault.WithVaultAddress("https://vault.elvia.io"))
+
+```
+
+When Vault is configured with login MFA, a login with any authentication method may require MFA before the token is
+issued. The MFA provider given with WithMFAProvider is then called for each MFA method, and the MFA is completed with
+Vault. For methods with a passcode, such as TOTP, the provider returns the passcode, while for methods such as Duo or
+Okta push, the login is approved on another device. PromptPasscode does both in the terminal. Without an MFA provider,
+logins that require a passcode fail.
+
 RENEWAL OF TOKEN
-The client will periodically renew the authentication token. The token is renewed when it has less than 30 seconds
-left to live. The token is renewed in a separate goroutine, so the client will not block while waiting for the token
-to be renewed.
+The client will periodically renew the authentication token. The token is renewed according to the RenewalPolicy set
+with WithRenewalPolicy, by default after 2/3 of its lease, and at least 30 seconds before it expires. The token is
+renewed in a separate goroutine, so the client will not block while waiting for the token to be renewed. Tokens from
+OIDC and username and password logins are renewed in Vault, so the user is only asked to log in again once the token
+reaches its max TTL.
 
 INSTRUMENTATION
 The package uses the OpenTelemetry SDK for Go for tracing as well as *log.Logger for simple logging. It is up to the
 client to configure the tracer and logger. The logger is set with the option WithLogger. If not set, a noop logger is
 used by default. Tracing is configured via otel.SetTracerProvider
 
+For structured logging, a *slog.Logger can be set with WithSlogLogger, or a logr.Logger with WithLogrLogger. Besides
+the message, the records have the following fields where they apply: component, path, method, status, request_id,
+lease_duration (in seconds) and error. The keys are fixed, so that the records can be processed by log pipelines, e.g.
+as JSON. Token and secret values are never logged:
+```
+
+	v, errChan, err := hashivault.New(ctx,
+		hashivault.WithSlogLogger(slog.New(slog.NewJSONHandler(os.Stderr, nil))),
+		hashivault.WithVaultAddress("https://vault.dev-elvia.io"))
+
+```
+
+Metrics are recorded with the meter provider configured via otel.SetMeterProvider, using a meter with the same name as
+the tracer. The following metrics are recorded:
+ 1. hashivault.logins counts logins to Vault, by authentication method and outcome (success or failure).
+ 2. hashivault.secret.reads counts secrets read from Vault, by path and outcome.
+ 3. hashivault.secret.refreshes counts refreshes of secrets kept up to date, by path, kind (renew or rotate) and
+    outcome.
+ 4. hashivault.request.duration is a histogram of the latency of requests to Vault in seconds, by operation (login,
+    read or write) and outcome.
+ 5. hashivault.token.ttl is a gauge of the seconds until the Vault token expires.
+ 6. hashivault.secret.lease.ttl is a gauge of the seconds until the lease of each secret kept up to date expires, by
+    path. Together with the token ttl, this can be used to alert on credentials that are about to expire.
+ 7. hashivault.secrets.evergreen is a gauge of the number of secrets kept up to date.
+
 GENERAL USAGE AND ABSTRACTIONS
 The main abstraction of this package is the SecretsManager interface. A new instance of SecretsManager is created
 with the New function. The returned SecretsManager is safe to use concurrently. Normally, only a single instance of
@@ -72,13 +193,135 @@ point is that the returned function will always return the latest version of the
 save a reference to the function rather than saving the actual secrets, and invoke the func just-in-time as the
 secret is needed. The returned function is safe to use concurrently.
 
+The function always returns the last value it got, also after its lease has expired and refreshing it has failed.
+Callers that must not use stale credentials can use the Secret method instead, whose Get method also tells when the
+value was refreshed, when it expires and whether the last refresh failed. If a refresh is in flight, Get waits for it
+until ctx is done:
+```
+
+	s, err := v.Secret(ctx, "kunde/database/creds/kunde")
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, info, err := s.Get(ctx)
+	if err != nil || info.Stale() {
+		return fmt.Errorf("no fresh database credentials: %w", err)
+	}
+
+```
+
 The SecretsManager interface also provides a method for setting the default Google credentials for the current
-process.
+process (the credentials file is kept up to date and removed when the SecretsManager is closed), as well as a method for exporting the keys of a secret as environment variables for SDKs that can only be
+configured that way (such as the Azure SDK, Datadog and Sentry):
+```
+
+	err := v.ExportEnv(ctx, "kunde/kv/data/azure/kunde", hashivault.EnvMapping{
+		Keys:        map[string]string{"client-id": "client_id", "client-secret": "client_secret"},
+		Prefix:      "AZURE_",
+		Uppercase:   true,
+		NoOverwrite: true,
+	})
+
+```
+
+Short-lived Google credentials can be issued by the GCP secrets engine, either as an OAuth2 token source for the
+Google client libraries (GoogleTokenSource) or as a service account key in the format of a credentials file
+(GoogleCredentialsJSON). Both are renewed automatically as the credentials expire:
+```
+
+	ts, err := v.GoogleTokenSource(ctx, "gcp/roleset/my-roleset/token")
+	if err != nil {
+		log.Fatal(err)
+	}
+	client, err := storage.NewClient(ctx, option.WithTokenSource(ts))
+
+```
+
+Likewise, short-lived AWS credentials can be issued by the AWS secrets engine as a provider for the AWS SDK:
+```
+
+	provider, err := v.AWSCredentialsProvider(ctx, "aws/sts/etl")
+	if err != nil {
+		log.Fatal(err)
+	}
+	client := s3.NewFromConfig(aws.Config{Region: "eu-north-1", Credentials: provider})
+
+```
+
+Dynamic Azure service principals are issued by the Azure secrets engine. The returned credential can be used with
+the Azure SDK, and it waits for new service principals to be replicated in Azure AD before using them:
+```
+
+	cred, err := v.AzureCredential(ctx, "azure/creds/my-role", tenantID,
+		hashivault.WithAzureProbe("https://management.azure.com/.default"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	client, err := armresources.NewClient(subscriptionID, cred, nil)
+
+```
+
+SSH user certificates are signed by the SSH secrets engine. The returned signer is re-signed before the certificate
+expires, according to the renewal policy, and can be used directly with golang.org/x/crypto/ssh:
+```
+
+	signer, err := v.SSHSigner(ctx, "ssh/sign/deploy", key, hashivault.WithSSHPrincipals("deploy"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	config := &ssh.ClientConfig{User: "deploy", Auth: []ssh.AuthMethod{ssh.PublicKeys(signer)}}
+
+```
+
+Secrets can be handed to other processes with response wrapping. Wrap stores the data in Vault and returns a
+single-use token with a limited lifetime, which the receiver exchanges for the data with Unwrap (or "vault unwrap"):
+```
+
+	token, err := v.Wrap(ctx, map[string]any{"password": password}, 5*time.Minute)
+
+```
+
+Legacy applications that only read configuration files can be served by rendering a text/template to a file with the
+Render method. The file is rewritten atomically whenever one of the referenced secrets changes, and an optional
+reload command or signal tells the application to pick up the new file. The file is kept up to date until ctx is
+done, after which the referenced secrets are no longer renewed:
+```
+
+	tmpl := `password = {{ secret "kunde/database/creds/kunde" "password" }}`
+	err := v.Render(ctx, tmpl, "/etc/app/app.conf", 0600, hashivault.WithReloadSignal(pid, syscall.SIGHUP))
+
+```
+
+The token and the leases of secrets are renewed well before they expire, according to a RenewalPolicy. By default,
+a lease is renewed after 2/3 of its duration, minus up to 10% jitter, and at least 30 seconds before it expires. If a
+renewal fails, it is retried sooner and sooner while the lease is still valid. The policy is set for the
+SecretsManager with WithRenewalPolicy, and can be overridden for a single secret with WithSecretRenewalPolicy:
+```
+
+	s, err := v.Secret(ctx, "kunde/database/creds/kunde", hashivault.WithSecretRenewalPolicy(hashivault.RenewalPolicy{
+		Fraction: 0.5,
+		Jitter:   0.1,
+		MinGrace: 5 * time.Minute,
+	}))
+
+```
+
+Secrets with a lease are kept up to date by renewing the lease, so the data stays the same. When Vault grants less
+than the original TTL, the lease has reached its max TTL, and a new secret is fetched before the lease expires. The
+lease that is replaced is revoked a minute later, so that requests using the old secret can complete. A renewal that
+fails is retried while the lease is valid, and a new secret is only fetched if Vault reports the lease as invalid or
+it has expired. The current lease of a secret can be revoked with the Revoke method of Secret, and the leases of all
+secrets are revoked by Close if WithRevokeOnClose is set.
 
 The token refresh functionality runs in a separate goroutine, and also a new goroutine will be started for each
-fetched secret that is renewable and has a lease duration. In order to communicate errors from these goroutines, the
-New function returns a channel of errors in addition to the SecretsManager. Clients should start a goroutine that
-reads from this channel and handles errors as appropriate.
+fetched secret that is renewable and has a lease duration. Errors from these goroutines are given as Events to the
+handler set with WithErrorHandler. An Event tells which component failed (e.g. the token or a secret), the path of
+the secret, how many consecutive attempts have failed, and when. The goroutines never wait for the handler, so if it
+falls behind, events are dropped and counted by the EventStats method. The goroutines are stopped by the Close method
+of the SecretsManager, which should be called when the application shuts down.
+
+The New function also returns a channel of errors, which is deprecated. The events are sent on it too, but they are
+dropped rather than blocking the goroutines if the channel is not drained.
 
 The following example shows how to use the SecretsManager:
 ```
@@ -112,22 +355,19 @@ import (
 		)
 		otel.SetTracerProvider(tp)
 
-		v, errChan, err := hashivault.New(
+		v, _, err := hashivault.New(
 			ctx,
 			hashivault.WithOIDC(),
 			hashivault.WithVaultAddress("https://vault.dev-elvia.io"),
 			hashivault.WithLogger(l),
+			hashivault.WithErrorHandler(func(e hashivault.Event) {
+				log.Printf("vault %s %s failed %d times: %s", e.Component, e.Path, e.Attempt, e.Err)
+			}),
 		)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		go func(ec <-chan error) {
-			for err := range ec {
-				log.Println(err)
-			}
-		}(errChan)
-
 		secret, err := v.GetSecret(ctx, "kunde/kv/data/appinsights/kunde")
 		if err != nil {
 			log.Fatal(err)
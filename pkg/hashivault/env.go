@@ -0,0 +1,157 @@
+package hashivault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/3lvia/hashivault-go/internal/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"os"
+	"sort"
+	"strings"
+)
+
+// EnvMapping describes how the keys of a secret are turned into environment variables.
+type EnvMapping struct {
+	// Keys maps secret keys to environment variable names. Only the keys present in the map are exported, and it is
+	// an error if the secret doesn't contain one of them. A key mapped to the empty string keeps its own name. If Keys
+	// is empty, all keys in the secret are exported using their own names.
+	Keys map[string]string
+
+	// Prefix is prepended to the name of every exported variable, e.g. "AZURE_".
+	Prefix string
+
+	// Uppercase normalises the variable names to upper case and replaces characters that are not allowed in
+	// environment variable names with underscores, e.g. "client-id" becomes "CLIENT_ID".
+	Uppercase bool
+
+	// NoOverwrite leaves variables that are already set in the environment of the current process untouched.
+	NoOverwrite bool
+}
+
+// Environ returns the environment variables for the given secret in the form "key=value", sorted by key. Values that
+// are not strings are JSON encoded, i.e. numbers and booleans are exported verbatim.
+func (em EnvMapping) Environ(secrets map[string]any) ([]string, error) {
+	vars, err := em.vars(secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	env := make([]string, 0, len(names))
+	for _, name := range names {
+		env = append(env, name+"="+vars[name])
+	}
+	return env, nil
+}
+
+func (em EnvMapping) vars(secrets map[string]any) (map[string]string, error) {
+	keys := em.Keys
+	if len(keys) == 0 {
+		keys = make(map[string]string, len(secrets))
+		for k := range secrets {
+			keys[k] = ""
+		}
+	}
+
+	vars := make(map[string]string, len(keys))
+	for key, name := range keys {
+		v, ok := secrets[key]
+		if !ok {
+			return nil, fmt.Errorf("key %s not found in secret", key)
+		}
+		if name == "" {
+			name = key
+		}
+		name = em.name(name)
+		if name == "" {
+			return nil, fmt.Errorf("key %s maps to an empty environment variable name", key)
+		}
+		if em.NoOverwrite {
+			if _, set := os.LookupEnv(name); set {
+				continue
+			}
+		}
+
+		s, err := envValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("while encoding key %s: %w", key, err)
+		}
+		vars[name] = s
+	}
+	return vars, nil
+}
+
+func (em EnvMapping) name(n string) string {
+	n = em.Prefix + n
+	if !em.Uppercase {
+		return n
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, n)
+}
+
+// envValue converts a secret value to its string representation.
+func envValue(v any) (string, error) {
+	switch t := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return t, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (m *manager) ExportEnv(ctx context.Context, path string, mapping EnvMapping) error {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(
+		ctx,
+		"hashivault.ExportEnv",
+		trace.WithAttributes(attribute.String("path", path)))
+	defer span.End()
+
+	m.l.With(logging.Path(path)).Printf("exporting environment variables from %s", path)
+
+	// the values are exported once, so the secret is not kept up to date
+	sec, err := m.read(spanCtx, path)
+	if err != nil {
+		traceError(span, err, m.l)
+		return err
+	}
+
+	vars, err := mapping.vars(sec.data())
+	if err != nil {
+		traceError(span, err, m.l)
+		return err
+	}
+
+	for name, value := range vars {
+		if err := os.Setenv(name, value); err != nil {
+			traceError(span, err, m.l)
+			return err
+		}
+	}
+
+	m.l.With(logging.Path(path)).Printf("exported %d environment variables from %s", len(vars), path)
+
+	return nil
+}
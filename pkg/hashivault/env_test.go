@@ -0,0 +1,126 @@
+package hashivault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestEnvMapping_Environ(t *testing.T) {
+	secrets := map[string]any{
+		"client-id":     "my-client-id",
+		"client.secret": "my-client-secret",
+		"port":          float64(8080),
+		"debug":         true,
+	}
+
+	tests := []struct {
+		name    string
+		mapping EnvMapping
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "all keys",
+			mapping: EnvMapping{},
+			want:    []string{"client-id=my-client-id", "client.secret=my-client-secret", "debug=true", "port=8080"},
+		},
+		{
+			name:    "all keys uppercase with prefix",
+			mapping: EnvMapping{Prefix: "azure_", Uppercase: true},
+			want: []string{
+				"AZURE_CLIENT_ID=my-client-id",
+				"AZURE_CLIENT_SECRET=my-client-secret",
+				"AZURE_DEBUG=true",
+				"AZURE_PORT=8080",
+			},
+		},
+		{
+			name: "renamed keys",
+			mapping: EnvMapping{
+				Keys:   map[string]string{"client-id": "ID", "port": ""},
+				Prefix: "APP_",
+			},
+			want: []string{"APP_ID=my-client-id", "APP_port=8080"},
+		},
+		{
+			name:    "missing key",
+			mapping: EnvMapping{Keys: map[string]string{"tenant-id": ""}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.mapping.Environ(secrets)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Environ() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Environ() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvMapping_Environ_noOverwrite(t *testing.T) {
+	t.Setenv("HV_TEST_EXISTING", "original")
+
+	got, err := EnvMapping{Prefix: "HV_TEST_", Uppercase: true, NoOverwrite: true}.Environ(map[string]any{
+		"existing": "new",
+		"other":    "value",
+	})
+	NoErr(t, err)
+
+	want := []string{"HV_TEST_OTHER=value"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Environ() = %v, want %v", got, want)
+	}
+}
+
+func Test_manager_ExportEnv(t *testing.T) {
+	ctx := context.Background()
+
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kunde/kv/data/appinsights/kunde" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintln(w, jsonStaticSecret)
+	})
+
+	t.Setenv("HV_EXPORT_INSTRUMENTATION_KEY", "")
+	os.Unsetenv("HV_EXPORT_INSTRUMENTATION_KEY")
+
+	err := m.ExportEnv(ctx, "kunde/kv/data/appinsights/kunde", EnvMapping{Prefix: "hv_export_", Uppercase: true})
+	NoErr(t, err)
+
+	if got := os.Getenv("HV_EXPORT_INSTRUMENTATION_KEY"); got != "my-secret-instrumentation-key" {
+		t.Errorf("unexpected environment variable value, got: %s", got)
+	}
+}
+
+func Test_manager_ExportEnv_renewable(t *testing.T) {
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"renewable":      true,
+			"lease_duration": 3600,
+			"data":           map[string]any{"username": "v-app-1"},
+		})
+	})
+
+	t.Setenv("HV_EXPORT_USERNAME", "")
+
+	err := m.ExportEnv(context.Background(), "database/creds/app", EnvMapping{Prefix: "hv_export_", Uppercase: true})
+	NoErr(t, err)
+
+	if got := os.Getenv("HV_EXPORT_USERNAME"); got != "v-app-1" {
+		t.Errorf("unexpected environment variable value, got: %s", got)
+	}
+	if len(m.secrets) != 0 {
+		t.Errorf("expected the exported secret not to be kept up to date, got %d secrets", len(m.secrets))
+	}
+}
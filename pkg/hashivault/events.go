@@ -0,0 +1,178 @@
+package hashivault
+
+import (
+	"fmt"
+	"github.com/3lvia/hashivault-go/internal/logging"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// eventQueueSize is how many events can wait for the error handler before further events are dropped.
+const eventQueueSize = 64
+
+// errChanSize is the buffer size of the deprecated error channel returned by New.
+const errChanSize = 16
+
+// Component identifies the background job of the SecretsManager that an Event comes from.
+type Component string
+
+const (
+	// ComponentToken is the job that obtains and renews the Vault token.
+	ComponentToken Component = "token"
+	// ComponentTokenFile is the job that re-reads the Vault token from a file.
+	ComponentTokenFile Component = "token_file"
+	// ComponentSecret is the job that refreshes a secret when its lease expires.
+	ComponentSecret Component = "secret"
+	// ComponentRender is the job that re-renders a template when a secret changes.
+	ComponentRender Component = "render"
+	// ComponentGoogleCredentials is the job that rewrites the Google credentials file.
+	ComponentGoogleCredentials Component = "google_credentials"
+	// ComponentGCP is the job that decodes new service account keys from the GCP secrets engine.
+	ComponentGCP Component = "gcp"
+	// ComponentAzure is the job that probes new service principals from the Azure secrets engine.
+	ComponentAzure Component = "azure"
+	// ComponentSSH is the job that re-signs SSH certificates.
+	ComponentSSH Component = "ssh"
+	// ComponentAWS is the AWS credentials provider, which revokes the credentials it has replaced.
+	ComponentAWS Component = "aws"
+)
+
+// Event describes an error in one of the background jobs of the SecretsManager. Event implements error, so it is
+// also what is sent on the deprecated error channel returned by New.
+type Event struct {
+	// Component is the background job the error occurred in.
+	Component Component
+
+	// Path is the path of the secret or file the job works on, if any.
+	Path string
+
+	// Attempt counts the consecutive failures of the job, starting at 1. It is reset when the job succeeds.
+	Attempt int
+
+	// Err is the error.
+	Err error
+
+	// Time is when the error occurred.
+	Time time.Time
+}
+
+func (e Event) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%s (attempt %d): %s", e.Component, e.Attempt, e.Err)
+	}
+	return fmt.Sprintf("%s %s (attempt %d): %s", e.Component, e.Path, e.Attempt, e.Err)
+}
+
+func (e Event) Unwrap() error {
+	return e.Err
+}
+
+// EventStats counts the events reported by the background jobs of the SecretsManager.
+type EventStats struct {
+	// Reported is the number of events reported.
+	Reported uint64
+
+	// Dropped is the number of events not given to the error handler, because the handler did not keep up.
+	Dropped uint64
+
+	// ChannelDropped is the number of events not sent on the deprecated error channel, because it was not drained.
+	ChannelDropped uint64
+}
+
+// WithErrorHandler sets a function that is called with the errors that occur in the background jobs of the
+// SecretsManager, such as renewing the token or refreshing a secret. The handler is called from a single goroutine, in
+// the order the errors occur. The jobs never wait for the handler, so if it falls behind, events are dropped and
+// counted in EventStats.
+func WithErrorHandler(handler func(Event)) Option {
+	return func(o *optionsCollector) {
+		o.errorHandler = handler
+	}
+}
+
+// events delivers the errors of the background jobs to the error handler and the deprecated error channel, without
+// ever blocking the jobs.
+type events struct {
+	errChan chan<- error
+	queue   chan Event
+	l       *logging.Logger
+
+	// attempts are the consecutive failures of each job, keyed by component and path.
+	mux      *sync.Mutex
+	attempts map[string]int
+
+	reported       atomic.Uint64
+	dropped        atomic.Uint64
+	channelDropped atomic.Uint64
+}
+
+func newEvents(errChan chan<- error, l *logging.Logger) *events {
+	return &events{
+		errChan:  errChan,
+		l:        l,
+		mux:      &sync.Mutex{},
+		attempts: map[string]int{},
+	}
+}
+
+// handle calls handler with the reported events until done is closed.
+func (e *events) handle(done <-chan struct{}, handler func(Event)) {
+	queue := make(chan Event, eventQueueSize)
+	e.mux.Lock()
+	e.queue = queue
+	e.mux.Unlock()
+
+	go func() {
+		for {
+			select {
+			case ev := <-queue:
+				handler(ev)
+			case <-done:
+				return
+			}
+		}
+	}()
+}
+
+// report reports that the job identified by component and path failed with err.
+func (e *events) report(component Component, path string, err error) {
+	key := string(component) + "\x00" + path
+	e.mux.Lock()
+	e.attempts[key]++
+	ev := Event{Component: component, Path: path, Attempt: e.attempts[key], Err: err, Time: time.Now()}
+	queue := e.queue
+	e.mux.Unlock()
+
+	e.reported.Add(1)
+	e.l.With(logging.Component(string(component)), logging.Path(path), logging.Error(err)).Errorf("error: %s", ev)
+
+	if queue != nil {
+		select {
+		case queue <- ev:
+		default:
+			e.dropped.Add(1)
+		}
+	}
+	if e.errChan != nil {
+		select {
+		case e.errChan <- ev:
+		default:
+			e.channelDropped.Add(1)
+		}
+	}
+}
+
+// resolved reports that the job identified by component and path succeeded, which resets its attempts.
+func (e *events) resolved(component Component, path string) {
+	e.mux.Lock()
+	delete(e.attempts, string(component)+"\x00"+path)
+	e.mux.Unlock()
+}
+
+func (e *events) stats() EventStats {
+	return EventStats{
+		Reported:       e.reported.Load(),
+		Dropped:        e.dropped.Load(),
+		ChannelDropped: e.channelDropped.Load(),
+	}
+}
@@ -0,0 +1,66 @@
+package hashivault
+
+import (
+	"errors"
+	"github.com/3lvia/hashivault-go/internal/logging"
+	"testing"
+	"time"
+)
+
+func Test_events_report(t *testing.T) {
+	errChan := make(chan error, 1)
+	ev := newEvents(errChan, logging.New(nil, nil))
+
+	done := make(chan struct{})
+	defer close(done)
+	handled := make(chan Event, 10)
+	ev.handle(done, func(e Event) {
+		handled <- e
+	})
+
+	errVault := errors.New("permission denied")
+
+	// nobody drains the channel, so reporting must not block
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ev.report(ComponentSecret, "kunde/kv/data/db", errVault)
+		ev.report(ComponentSecret, "kunde/kv/data/db", errVault)
+		ev.resolved(ComponentSecret, "kunde/kv/data/db")
+		ev.report(ComponentSecret, "kunde/kv/data/db", errVault)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("report blocked")
+	}
+
+	var attempts []int
+	for i := 0; i < 3; i++ {
+		select {
+		case e := <-handled:
+			if e.Component != ComponentSecret || e.Path != "kunde/kv/data/db" || e.Time.IsZero() {
+				t.Errorf("unexpected event: %+v", e)
+			}
+			attempts = append(attempts, e.Attempt)
+		case <-time.After(time.Second):
+			t.Fatal("event was not handled")
+		}
+	}
+	if attempts[0] != 1 || attempts[1] != 2 || attempts[2] != 1 {
+		t.Errorf("unexpected attempts, got: %v", attempts)
+	}
+
+	err := <-errChan
+	if !errors.Is(err, errVault) {
+		t.Errorf("expected the channel to receive the event, got: %v", err)
+	}
+	if err.Error() != "secret kunde/kv/data/db (attempt 1): permission denied" {
+		t.Errorf("unexpected error message, got: %s", err)
+	}
+
+	stats := ev.stats()
+	if stats.Reported != 3 || stats.Dropped != 0 || stats.ChannelDropped != 2 {
+		t.Errorf("unexpected stats, got: %+v", stats)
+	}
+}
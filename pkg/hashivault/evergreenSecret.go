@@ -2,27 +2,35 @@ package hashivault
 
 import (
 	"context"
+	"errors"
+	"github.com/3lvia/hashivault-go/internal/logging"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
-	"log"
 	"net/http"
+	"reflect"
 	"sync"
 	"time"
 )
 
-func newEvergreen(path, vaultAddress string, sec *secret, tokenGetter tokenGetterFunc, client *http.Client, errChan chan<- error, l *log.Logger) *evergreenSecret {
+func newEvergreen(ctx context.Context, path, vaultAddress string, sec *secret, tokenGetter tokenGetterFunc, client *http.Client, renewal RenewalPolicy, retire func(leaseID string), ev *events, l *logging.Logger) *evergreenSecret {
+	ctx, cancel := context.WithCancel(ctx)
 	eg := &evergreenSecret{
 		path:         path,
 		sec:          sec,
+		ttl:          sec.LeaseDuration,
+		refreshed:    time.Now(),
+		cancel:       cancel,
 		mux:          &sync.Mutex{},
 		client:       client,
 		vaultAddress: vaultAddress,
 		tokenGetter:  tokenGetter,
+		renewal:      renewal,
+		retire:       retire,
 		l:            l,
 	}
 
-	go eg.start(errChan)
+	go eg.start(ctx, ev)
 
 	return eg
 }
@@ -34,7 +42,29 @@ type evergreenSecret struct {
 	sec          *secret
 	mux          *sync.Mutex
 	tokenGetter  tokenGetterFunc
-	l            *log.Logger
+	subscribers  []chan<- struct{}
+	renewal      RenewalPolicy
+	l            *logging.Logger
+
+	// retire is called with the lease of a secret that has been replaced while the lease is still valid.
+	retire func(leaseID string)
+
+	// refreshed is when sec was fetched, and lastErr and failures describe the refreshes that have failed since.
+	refreshed time.Time
+	lastErr   error
+	failures  int
+
+	// refreshing is closed when the refresh in flight completes, and nil if there is none.
+	refreshing chan struct{}
+
+	// ttl is the lease duration the secret was issued with, which is requested when the lease is renewed. If Vault
+	// grants less, the lease has reached its max TTL, and rotate is set so that a new secret is fetched next time.
+	ttl    int
+	rotate bool
+
+	// cancel stops refreshing the secret, and revoked is set when its lease has been revoked.
+	cancel  context.CancelFunc
+	revoked bool
 }
 
 func (e *evergreenSecret) get() map[string]any {
@@ -43,25 +73,194 @@ func (e *evergreenSecret) get() map[string]any {
 	return e.sec.data()
 }
 
-func (e *evergreenSecret) start(errChan chan<- error) {
+// getCtx returns the data of the secret together with information about its freshness. If a refresh is in flight, it
+// waits for the refresh to complete, or for ctx to be done, in which case the current data is returned with the error
+// of ctx.
+func (e *evergreenSecret) getCtx(ctx context.Context) (map[string]any, SecretInfo, error) {
+	e.mux.Lock()
+	refreshing := e.refreshing
+	e.mux.Unlock()
+
+	var err error
+	if refreshing != nil {
+		select {
+		case <-refreshing:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	return e.sec.data(), e.info(), err
+}
+
+// info returns the freshness of the secret. The caller must hold the lock.
+func (e *evergreenSecret) info() SecretInfo {
+	info := SecretInfo{
+		Refreshed:      e.refreshed,
+		LastError:      e.lastErr,
+		FailedAttempts: e.failures,
+	}
+	if e.sec.LeaseDuration > 0 {
+		info.Expires = e.refreshed.Add(time.Duration(e.sec.LeaseDuration) * time.Second)
+	}
+	return info
+}
+
+// subscribe registers a channel that is signalled every time the data of the secret changes. The send is
+// non-blocking, so the channel should be buffered, and a single signal may represent several changes.
+func (e *evergreenSecret) subscribe(ch chan<- struct{}) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	e.subscribers = append(e.subscribers, ch)
+}
+
+func (e *evergreenSecret) notify() {
+	for _, ch := range e.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// start refreshes the secret before its lease expires, as decided by the renewal policy, until ctx is done.
+func (e *evergreenSecret) start(ctx context.Context, ev *events) {
 	for {
-		<-time.After(time.Duration(e.sec.LeaseDuration) * time.Second)
 		e.mux.Lock()
+		wait := e.nextRefresh()
+		e.mux.Unlock()
 
-		tracer := otel.GetTracerProvider().Tracer(tracerName)
-		ctx, span := tracer.Start(
-			context.Background(),
-			"hashivault.evergreenSecret.start",
-			trace.WithAttributes(attribute.String("path", e.path)))
-		defer span.End()
-
-		sec, err := get(ctx, e.path, e.vaultAddress, e.tokenGetter(), e.client, e.l)
-		if err != nil {
-			errChan <- err
-			e.mux.Unlock()
-			continue
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
 		}
-		e.sec = sec
-		e.mux.Unlock()
+		e.refresh(ctx, ev)
+	}
+}
+
+// renewLease extends the lease of the given secret by the TTL it was issued with, and returns the secret with the lease
+// granted by Vault. The data is not returned when renewing, so it is kept from the given secret.
+func (e *evergreenSecret) renewLease(ctx context.Context, current *secret) (*secret, error) {
+	e.mux.Lock()
+	ttl := e.ttl
+	e.mux.Unlock()
+
+	lease, err := renewLease(ctx, current.LeaseID, ttl, e.vaultAddress, e.tokenGetter(), e.client, e.l)
+	if err != nil {
+		return nil, err
+	}
+
+	sec := *current
+	if lease.LeaseID != "" {
+		sec.LeaseID = lease.LeaseID
+	}
+	sec.Renewable = lease.Renewable
+	sec.LeaseDuration = lease.LeaseDuration
+	return &sec, nil
+}
+
+// expires returns the path of the secret and when its lease expires. It returns false if the secret is no longer kept
+// up to date.
+func (e *evergreenSecret) expires() (string, time.Time, bool) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	return e.path, e.info().Expires, !e.revoked
+}
+
+// nextRefresh returns how long to wait before refreshing the secret. If the last refresh failed, it is retried sooner,
+// while the data is still valid. The caller must hold the lock.
+func (e *evergreenSecret) nextRefresh() time.Duration {
+	if e.lastErr != nil {
+		return e.renewal.retryDelay(e.info().Expires)
+	}
+	return e.renewal.delay(time.Duration(e.sec.LeaseDuration) * time.Second)
+}
+
+// refresh renews the lease of the secret, or fetches a new secret if the lease cannot be renewed any further. The lock
+// is not held while talking to Vault, so the last data is available meanwhile.
+func (e *evergreenSecret) refresh(ctx context.Context, ev *events) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(
+		ctx,
+		"hashivault.evergreenSecret.start",
+		trace.WithAttributes(attribute.String("path", e.path)))
+	defer span.End()
+
+	done := make(chan struct{})
+	e.mux.Lock()
+	e.refreshing = done
+	current := e.sec
+	renew := current.LeaseID != "" && current.Renewable && !e.rotate
+	e.mux.Unlock()
+
+	var sec *secret
+	var err error
+	if renew {
+		sec, err = e.renewLease(spanCtx, current)
+		recordRefresh(spanCtx, e.path, "renew", err)
+	} else {
+		sec, err = get(spanCtx, e.path, e.vaultAddress, e.tokenGetter(), e.client, e.l)
+		recordRefresh(spanCtx, e.path, "rotate", err)
+	}
+
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	e.refreshing = nil
+	defer close(done)
+
+	if e.revoked {
+		return
+	}
+	if err != nil {
+		if renew && e.leaseGone(err) {
+			// a new secret is fetched on the next attempt, otherwise the renewal is retried while the lease is valid
+			e.l.With(logging.Path(e.path), logging.Error(err)).
+				Printf("lease of %s is no longer valid, fetching a new secret", e.path)
+			sec := *e.sec
+			sec.LeaseID = ""
+			e.sec = &sec
+		}
+		e.lastErr = err
+		e.failures++
+		ev.report(ComponentSecret, e.path, err)
+		return
+	}
+	ev.resolved(ComponentSecret, e.path)
+
+	if renew {
+		e.rotate = !sec.Renewable || sec.LeaseDuration < e.ttl
+		if e.rotate {
+			e.l.With(logging.Path(e.path), logging.LeaseDuration(time.Duration(sec.LeaseDuration)*time.Second)).
+				Printf("lease of %s reached its max TTL, fetching a new secret before it expires in %ds", e.path, sec.LeaseDuration)
+		}
+	} else {
+		if current.LeaseID != "" && current.LeaseID != sec.LeaseID {
+			e.retire(current.LeaseID)
+		}
+		e.ttl = sec.LeaseDuration
+		e.rotate = false
+	}
+
+	changed := !reflect.DeepEqual(e.sec.data(), sec.data())
+	e.sec = sec
+	e.refreshed = time.Now()
+	e.lastErr = nil
+	e.failures = 0
+	if changed {
+		e.notify()
+	}
+}
+
+// leaseGone reports whether the lease of the secret can no longer be renewed after a renewal failed with err, because
+// Vault reports it as invalid, e.g. when it has been revoked, or because it has expired. The caller must hold the lock.
+func (e *evergreenSecret) leaseGone(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) && se.code == http.StatusBadRequest {
+		return true
 	}
+	expires := e.info().Expires
+	return !expires.IsZero() && !time.Now().Before(expires)
 }
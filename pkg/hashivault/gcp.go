@@ -0,0 +1,166 @@
+package hashivault
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/3lvia/hashivault-go/internal/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/oauth2"
+	"sync"
+	"time"
+)
+
+// GoogleCredentialsFunc is a function that returns the JSON of a Google service account key, i.e. the content of a
+// credentials.json file. Like EvergreenSecretsFunc, the returned function will always return the latest version of the
+// key, so clients should save a reference to the function rather than the key itself. The function is safe to use
+// concurrently.
+type GoogleCredentialsFunc func() []byte
+
+func (m *manager) GoogleTokenSource(ctx context.Context, path string) (oauth2.TokenSource, error) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(
+		ctx,
+		"hashivault.GoogleTokenSource",
+		trace.WithAttributes(attribute.String("path", path)))
+	defer span.End()
+
+	m.l.With(logging.Path(path)).Printf("getting google token source from %s", path)
+
+	ts := &googleTokenSource{m: m, path: path}
+	t, err := ts.token(spanCtx)
+	if err != nil {
+		traceError(span, err, m.l)
+		return nil, err
+	}
+
+	return oauth2.ReuseTokenSource(t, ts), nil
+}
+
+// googleTokenSource issues a new OAuth2 access token from the GCP secrets engine every time Token is called. It is
+// wrapped in oauth2.ReuseTokenSource, so a new token is only issued when the current one is about to expire.
+type googleTokenSource struct {
+	m    *manager
+	path string
+}
+
+func (s *googleTokenSource) Token() (*oauth2.Token, error) {
+	return s.token(s.m.ctx)
+}
+
+func (s *googleTokenSource) token(ctx context.Context) (*oauth2.Token, error) {
+	sec, err := s.m.read(ctx, s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	d := sec.data()
+	accessToken, ok := d["token"].(string)
+	if !ok || accessToken == "" {
+		return nil, fmt.Errorf("no token found in secret %s", s.path)
+	}
+
+	t := &oauth2.Token{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+	}
+	if exp, ok := d["expires_at_seconds"].(float64); ok {
+		t.Expiry = time.Unix(int64(exp), 0)
+	} else if ttl, ok := d["token_ttl"].(float64); ok {
+		t.Expiry = time.Now().Add(time.Duration(ttl) * time.Second)
+	}
+
+	s.m.l.Printf("got google access token from %s, expires at %s", s.path, t.Expiry.Format(time.RFC3339))
+	return t, nil
+}
+
+func (m *manager) GoogleCredentialsJSON(ctx context.Context, path string) (GoogleCredentialsFunc, error) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(
+		ctx,
+		"hashivault.GoogleCredentialsJSON",
+		trace.WithAttributes(attribute.String("path", path)))
+	defer span.End()
+
+	m.l.With(logging.Path(path)).Printf("getting google credentials from %s", path)
+
+	sec, es, err := m.getSecret(spanCtx, path, m.renewal)
+	if err != nil {
+		traceError(span, err, m.l)
+		return nil, err
+	}
+
+	creds, err := googleServiceAccountKey(sec.data())
+	if err != nil {
+		traceError(span, err, m.l)
+		return nil, fmt.Errorf("while decoding key from %s: %w", path, err)
+	}
+
+	if es == nil {
+		return func() []byte { return creds }, nil
+	}
+
+	k := &googleKey{mux: &sync.Mutex{}, creds: creds}
+	changed := make(chan struct{}, 1)
+	es.subscribe(changed)
+	go k.start(m, path, es, changed)
+
+	return k.get, nil
+}
+
+// googleKey keeps the decoded service account key of an evergreen secret up to date.
+type googleKey struct {
+	mux   *sync.Mutex
+	creds []byte
+}
+
+func (k *googleKey) get() []byte {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	return k.creds
+}
+
+// start decodes the key every time the secret changes, until the manager is closed. If the new key cannot be decoded
+// the previous one is kept.
+func (k *googleKey) start(m *manager, path string, es *evergreenSecret, changed <-chan struct{}) {
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-changed:
+		}
+
+		creds, err := googleServiceAccountKey(es.get())
+		if err != nil {
+			m.events.report(ComponentGCP, path, fmt.Errorf("while decoding key: %w", err))
+			continue
+		}
+		m.events.resolved(ComponentGCP, path)
+
+		k.mux.Lock()
+		k.creds = creds
+		k.mux.Unlock()
+		m.l.With(logging.Path(path)).Printf("google credentials from %s rotated", path)
+	}
+}
+
+// googleServiceAccountKey decodes the service account key returned by the GCP secrets engine, which is the base64
+// encoded credentials file.
+func googleServiceAccountKey(d map[string]any) ([]byte, error) {
+	encoded, ok := d["private_key_data"].(string)
+	if !ok {
+		return nil, fmt.Errorf("private_key_data not found in secret")
+	}
+
+	creds, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(creds) {
+		return nil, fmt.Errorf("private_key_data is not a credentials file")
+	}
+	return creds, nil
+}
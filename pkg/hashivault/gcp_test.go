@@ -0,0 +1,79 @@
+package hashivault
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_manager_GoogleTokenSource(t *testing.T) {
+	tokenCount := 0
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/gcp/roleset/my-roleset/token" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		tokenCount++
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{
+				"token":              "ya29.my-access-token",
+				"expires_at_seconds": time.Now().Add(time.Hour).Unix(),
+				"token_ttl":          3599,
+			},
+		})
+	})
+
+	ts, err := m.GoogleTokenSource(context.Background(), "gcp/roleset/my-roleset/token")
+	NoErr(t, err)
+
+	for i := 0; i < 2; i++ {
+		tok, err := ts.Token()
+		NoErr(t, err)
+		if tok.AccessToken != "ya29.my-access-token" {
+			t.Errorf("unexpected access token, got: %s", tok.AccessToken)
+		}
+		if until := time.Until(tok.Expiry); until < 59*time.Minute || until > time.Hour {
+			t.Errorf("unexpected expiry, got: %s", tok.Expiry)
+		}
+	}
+
+	if tokenCount != 1 {
+		t.Errorf("expected the token to be reused, got %d tokens", tokenCount)
+	}
+}
+
+func Test_manager_GoogleTokenSource_noToken(t *testing.T) {
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]any{"errors": []string{"permission denied"}})
+	})
+
+	if _, err := m.GoogleTokenSource(context.Background(), "gcp/roleset/my-roleset/token"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func Test_manager_GoogleCredentialsJSON(t *testing.T) {
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"lease_id":       "gcp/static-account/my-account/key/abc",
+			"renewable":      false,
+			"lease_duration": 3600,
+			"data": map[string]any{
+				"key_algorithm":    "KEY_ALG_RSA_2048",
+				"key_type":         "TYPE_GOOGLE_CREDENTIALS_FILE",
+				"private_key_data": base64.StdEncoding.EncodeToString([]byte(googleCredentialsJSON)),
+			},
+		})
+	})
+
+	creds, err := m.GoogleCredentialsJSON(context.Background(), "gcp/static-account/my-account/key")
+	NoErr(t, err)
+
+	if string(creds()) != googleCredentialsJSON {
+		t.Errorf("unexpected credentials, got: %s", creds())
+	}
+}
@@ -0,0 +1,167 @@
+package hashivault
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"os"
+	"path/filepath"
+)
+
+const googleCredentialsFile = "google-credentials.json"
+
+func (m *manager) SetDefaultGoogleCredentials(ctx context.Context, path, key string) (string, error) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(
+		ctx,
+		"hashivault.SetDefaultGoogleCredentials",
+		trace.WithAttributes(attribute.String("path", path)))
+	defer span.End()
+
+	m.l.Print("setting default google credentials")
+
+	sec, es, err := m.getSecret(spanCtx, path, m.renewal)
+	if err != nil {
+		traceError(span, err, m.l)
+		return "", err
+	}
+
+	s := EvergreenSecretsFunc(sec.data)
+	if es != nil {
+		s = es.get
+	}
+
+	creds, err := googleCredentials(s(), key)
+	if err != nil {
+		traceError(span, err, m.l)
+		return "", err
+	}
+
+	fn, err := m.googleCredentialsPath()
+	if err != nil {
+		traceError(span, err, m.l)
+		return "", err
+	}
+
+	if err := writeFileAtomic(fn, creds, 0600); err != nil {
+		traceError(span, err, m.l)
+		return "", err
+	}
+	m.addFile(fn)
+
+	if err := os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", fn); err != nil {
+		traceError(span, err, m.l)
+		return "", err
+	}
+
+	if es != nil {
+		changed := make(chan struct{}, 1)
+		es.subscribe(changed)
+		rewriteCtx, cancel := context.WithCancel(m.ctx)
+		m.watchGoogleCredentials(path, func() {
+			cancel()
+			if err := m.stopSecret(es); err != nil {
+				m.events.report(ComponentGoogleCredentials, fn, err)
+			}
+		})
+		go m.rewriteGoogleCredentials(rewriteCtx, fn, key, es, changed)
+	}
+
+	m.l.Printf("set default google credentials to %s", fn)
+
+	return fn, nil
+}
+
+// googleCredentialsPath returns the path of the credentials file, creating a temporary directory for it unless a
+// directory has been configured.
+func (m *manager) googleCredentialsPath() (string, error) {
+	if m.googleCredentialsDir != "" {
+		return filepath.Join(m.googleCredentialsDir, googleCredentialsFile), nil
+	}
+
+	dir, err := os.MkdirTemp("", "hashivault-")
+	if err != nil {
+		return "", fmt.Errorf("while creating directory for google credentials: %w", err)
+	}
+	m.addFile(dir)
+
+	return filepath.Join(dir, googleCredentialsFile), nil
+}
+
+// watchGoogleCredentials registers the function that stops rewriting the credentials from the given path, and calls
+// the one registered by a previous call for the same path, so that the credentials are only kept up to date once.
+func (m *manager) watchGoogleCredentials(path string, stop context.CancelFunc) {
+	m.mux.Lock()
+	prev := m.googleCreds[path]
+	m.googleCreds[path] = stop
+	m.mux.Unlock()
+
+	if prev != nil {
+		prev()
+	}
+}
+
+// rewriteGoogleCredentials rewrites the credentials file every time the secret changes, until ctx is done, i.e. the
+// manager is closed or the credentials are set again from the same path.
+func (m *manager) rewriteGoogleCredentials(ctx context.Context, fn, key string, es *evergreenSecret, changed <-chan struct{}) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		}
+
+		m.l.Printf("google credentials changed, rewriting %s", fn)
+
+		creds, err := googleCredentials(es.get(), key)
+		if err != nil {
+			m.events.report(ComponentGoogleCredentials, fn, err)
+			continue
+		}
+
+		// the lock prevents the file from being rewritten after Close has removed it
+		m.mux.Lock()
+		if ctx.Err() == nil {
+			err = writeFileAtomic(fn, creds, 0600)
+		}
+		m.mux.Unlock()
+		if err != nil {
+			m.events.report(ComponentGoogleCredentials, fn, err)
+			continue
+		}
+		m.events.resolved(ComponentGoogleCredentials, fn)
+	}
+}
+
+func (m *manager) addFile(name string) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.files = append(m.files, name)
+}
+
+// googleCredentials returns the credentials stored under the given key, which may be either the raw JSON of the
+// credentials file or its base64 encoding.
+func googleCredentials(sm map[string]any, key string) ([]byte, error) {
+	if _, ok := sm[key]; !ok {
+		return nil, fmt.Errorf("key %s not found in secret", key)
+	}
+	encoded, ok := sm[key].(string)
+	if !ok {
+		return nil, fmt.Errorf("key %s is not a string", key)
+	}
+
+	trimmed := bytes.TrimSpace([]byte(encoded))
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return trimmed, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(string(trimmed))
+	if err != nil {
+		return nil, fmt.Errorf("key %s is neither JSON nor base64: %w", key, err)
+	}
+	return decoded, nil
+}
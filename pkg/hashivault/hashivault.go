@@ -3,9 +3,10 @@ package hashivault
 import (
 	"context"
 	"fmt"
+	"github.com/3lvia/hashivault-go/internal/auth"
+	"github.com/3lvia/hashivault-go/internal/logging"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"log"
 	"net/http"
 	"sync"
 )
@@ -13,21 +14,11 @@ import (
 // New returns a new SecretsManager and also a channel that will send errors that may arise in the concurrent internal
 // goroutines that will run in the whole lifetime of the service after this function. The returned error indicates that
 // something went wrong during initialization, and the service will not be able to run (if it is not nil).
+//
+// The error channel is deprecated, and only kept for compatibility. Errors are sent on it as Events without waiting,
+// so they are dropped if the channel is not drained. Use WithErrorHandler to handle errors instead.
 func New(ctx context.Context, opts ...Option) (SecretsManager, <-chan error, error) {
-	c := &optionsCollector{}
-	for _, opt := range opts {
-		opt(c)
-	}
-
-	l := c.logger
-	if l == nil {
-		l = log.New(nullWriter(1), "", log.LstdFlags)
-	}
-
-	tracerName = c.otelTracerName
-	if tracerName == "" {
-		tracerName = defaultTracerName
-	}
+	c, l := collectOptions(opts)
 
 	l.Printf("starting hashivault secrets manager with tracer: %s", tracerName)
 
@@ -43,25 +34,54 @@ func New(ctx context.Context, opts ...Option) (SecretsManager, <-chan error, err
 	span.SetAttributes(attribute.String("vault_address", c.vaultAddress))
 	l.Printf("using vault address: %s", c.vaultAddress)
 
-	errChan := make(chan error)
+	errChan := make(chan error, errChanSize)
 	client := c.client
 	if client == nil {
 		client = &http.Client{}
 	}
 
+	if err := c.checkHomeVaultTokenFile(spanCtx, client, l); err != nil {
+		traceError(span, err, l)
+		return nil, nil, fmt.Errorf("invalid options: %w", err)
+	}
+
+	m := newManager(c.vaultAddress, nil, errChan, l)
+	m.googleCredentialsDir = c.googleCredentialsDir
+	m.renewal = c.renewal()
+	m.revokeOnClose = c.revokeOnClose
+	if c.errorHandler != nil {
+		m.events.handle(m.ctx.Done(), c.errorHandler)
+	}
+
 	tokenGetter := func() string {
 		return c.vaultToken
 	}
-	if c.vaultToken != "" {
+	// with an auth chain, static tokens and token files are tried by the token job together with the other methods
+	chain := len(c.authChain) > 0
+	switch {
+	case !chain && c.vaultToken != "":
 		l.Print("using static vault token")
-	}
-	if c.vaultToken == "" {
+	case !chain && c.authMethod() == auth.MethodToken:
+		l.Printf("using vault token from %s", c.vaultTokenFile)
+		j, err := newTokenFileJob(c.vaultTokenFile, l)
+		if err != nil {
+			traceError(span, err, l)
+			m.Close()
+			return nil, nil, err
+		}
+		go j.start(m.ctx.Done(), m.events)
+		tokenGetter = j.token
+	default:
 		// initializedChan is used to signal that the tokenGetter has been initialized. This ensures that secrets are not
 		// requested before we have a valid token. This channel should only be used once, and no actual message will ever
 		// be sent on it. Instead, it will be closed when the tokenGetter has been initialized.
 		initializedChan := make(chan struct{})
 
-		tokenGetter = startTokenJob(spanCtx, c, errChan, initializedChan, client, l)
+		j := startTokenJob(spanCtx, m.ctx.Done(), c, m.events, initializedChan, client, l)
+		tokenGetter = j.token
+		m.mux.Lock()
+		m.tokenExpires = j.expires
+		m.mux.Unlock()
 
 		wg := &sync.WaitGroup{}
 		wg.Add(1)
@@ -75,6 +95,73 @@ func New(ctx context.Context, opts ...Option) (SecretsManager, <-chan error, err
 
 	l.Print("hashivault secrets manager initialized, ready to go!")
 
-	m := newManager(c.vaultAddress, tokenGetter, errChan, l)
+	m.tokenGetter = tokenGetter
 	return m, errChan, nil
 }
+
+// Login authenticates to Vault using the same options, environment variables and precedence as New, and returns the
+// resulting Vault token. Unlike New, no background jobs are started, so the token is not renewed. This is useful for
+// handing a token to other tools, such as the vault CLI.
+func Login(ctx context.Context, opts ...Option) (string, error) {
+	c, l := collectOptions(opts)
+
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(ctx, "hashivault.Login")
+	defer span.End()
+
+	if err := c.build(); err != nil {
+		traceError(span, err, l)
+		return "", fmt.Errorf("invalid options: %w", err)
+	}
+
+	chain := len(c.authChain) > 0
+	if !chain && c.vaultToken != "" {
+		l.Print("using static vault token")
+		return c.vaultToken, nil
+	}
+
+	client := c.client
+	if client == nil {
+		client = &http.Client{}
+	}
+
+	if err := c.checkHomeVaultTokenFile(spanCtx, client, l); err != nil {
+		traceError(span, err, l)
+		return "", fmt.Errorf("invalid options: %w", err)
+	}
+	if !chain && c.authMethod() == auth.MethodToken {
+		l.Printf("using vault token from %s", c.vaultTokenFile)
+		j, err := newTokenFileJob(c.vaultTokenFile, l)
+		if err != nil {
+			traceError(span, err, l)
+			return "", err
+		}
+		return j.token(), nil
+	}
+
+	j := newTokenJob(c, client, l)
+	ar, err := j.authenticate(spanCtx)
+	if err != nil {
+		traceError(span, err, l)
+		return "", err
+	}
+
+	return ar.ClientToken(), nil
+}
+
+// collectOptions applies the given options and returns the resulting collector together with the logger to use.
+func collectOptions(opts []Option) (*optionsCollector, *logging.Logger) {
+	c := &optionsCollector{}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	l := logging.New(c.logger, c.slogLogger)
+
+	tracerName = c.otelTracerName
+	if tracerName == "" {
+		tracerName = defaultTracerName
+	}
+
+	return c, l
+}
@@ -58,6 +58,7 @@ func TestNew_static(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer sm.Close()
 
 	go func(ec <-chan error) {
 		e := <-ec
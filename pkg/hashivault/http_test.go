@@ -2,6 +2,7 @@ package hashivault
 
 import (
 	"fmt"
+	"github.com/3lvia/hashivault-go/internal/logging"
 	"net/http"
 	"net/http/httptest"
 	"sync"
@@ -25,6 +26,7 @@ func startTestServer(t *testing.T) (string, *http.Client, closerFunc) {
 		mux.Lock()
 		defer mux.Unlock()
 		testServer.Close()
+		testServer = nil
 	}
 
 	if testServer != nil {
@@ -60,3 +62,16 @@ func addHandler(path string, handler http.HandlerFunc) {
 	defer mux.Unlock()
 	handlers[path] = handler
 }
+
+// newTestManager starts a test server with the given handler, and returns a manager that reads from it with the token
+// "token". The manager and the server are closed when the test ends.
+func newTestManager(t *testing.T, handler http.HandlerFunc) *manager {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	m := newManager(server.URL, func() string { return "token" }, nil, logging.New(nil, nil))
+	t.Cleanup(func() { m.Close() })
+	return m
+}
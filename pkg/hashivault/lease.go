@@ -0,0 +1,203 @@
+package hashivault
+
+import (
+	"context"
+	"github.com/3lvia/hashivault-go/internal/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"time"
+)
+
+// revokeTimeout is how long Close waits for Vault to revoke the outstanding leases.
+const revokeTimeout = 10 * time.Second
+
+// replacedLeaseDelay is how long the lease of a replaced secret is kept before it is revoked, so that requests that
+// use the replaced secret can complete.
+const replacedLeaseDelay = time.Minute
+
+// WithRevokeOnClose makes Close revoke the leases of the secrets that the SecretsManager keeps up to date, and of the
+// current AWS credentials, so that dynamic credentials do not outlive the service. By default, the leases are left to
+// expire.
+func WithRevokeOnClose() Option {
+	return func(o *optionsCollector) {
+		o.revokeOnClose = true
+	}
+}
+
+// Revoke revokes the lease of the secret in Vault and stops keeping it up to date, e.g. when the credentials are no
+// longer needed. After Revoke, Get keeps returning the last data, which is no longer valid.
+func (s *Secret) Revoke(ctx context.Context) error {
+	if s.es == nil {
+		return nil
+	}
+	s.m.mux.Lock()
+	delete(s.m.secrets, s.es)
+	s.m.mux.Unlock()
+	return s.es.revoke(ctx)
+}
+
+// stopSecret stops keeping the secret up to date and forgets it. If revokeOnClose is set, its lease is revoked right
+// away, since Close no longer does it.
+func (m *manager) stopSecret(es *evergreenSecret) error {
+	m.mux.Lock()
+	delete(m.secrets, es)
+	revoke := m.revokeOnClose
+	m.mux.Unlock()
+
+	if !revoke {
+		es.cancel()
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), revokeTimeout)
+	defer cancel()
+	return es.revoke(ctx)
+}
+
+// revoke stops refreshing the secret and revokes its current lease, if it has one and it has not been revoked already.
+func (e *evergreenSecret) revoke(ctx context.Context) error {
+	e.cancel()
+
+	e.mux.Lock()
+	leaseID := e.sec.leaseID()
+	if e.revoked {
+		leaseID = ""
+	}
+	e.revoked = true
+	e.mux.Unlock()
+
+	if leaseID == "" {
+		return nil
+	}
+	return revokeLease(ctx, leaseID, e.vaultAddress, e.tokenGetter(), e.client, e.l)
+}
+
+// revokeLeases revokes the leases of all secrets kept up to date by the manager, and the leases registered with
+// trackLease.
+func (m *manager) revokeLeases() []error {
+	ctx, cancel := context.WithTimeout(context.Background(), revokeTimeout)
+	defer cancel()
+
+	m.mux.Lock()
+	secrets := m.secrets
+	m.secrets = map[*evergreenSecret]struct{}{}
+	leases := m.leases
+	m.leases = map[string]struct{}{}
+	m.mux.Unlock()
+
+	var errs []error
+	for es := range secrets {
+		if err := es.revoke(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for leaseID := range leases {
+		if err := revokeLease(ctx, leaseID, m.vaultAddress, m.tokenGetter(), m.client, m.l); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// trackLease registers the lease of a secret that is not kept up to date by the manager, so that it is revoked by
+// Close like the leases of the secrets that are.
+func (m *manager) trackLease(leaseID string) {
+	if leaseID == "" {
+		return
+	}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.leases[leaseID] = struct{}{}
+}
+
+// replaceLease revokes a lease registered with trackLease, whose secret has been replaced and is no longer handed out.
+func (m *manager) replaceLease(ctx context.Context, leaseID string) error {
+	if leaseID == "" {
+		return nil
+	}
+	m.mux.Lock()
+	delete(m.leases, leaseID)
+	m.mux.Unlock()
+	return revokeLease(ctx, leaseID, m.vaultAddress, m.tokenGetter(), m.client, m.l)
+}
+
+// retireLease revokes the lease of a secret that has been replaced after revokeDelay. The lease is tracked until then,
+// so if the manager is closed meanwhile, it is left to Close.
+func (m *manager) retireLease(component Component, path, leaseID string) {
+	if leaseID == "" {
+		return
+	}
+	m.trackLease(leaseID)
+
+	go func() {
+		select {
+		case <-time.After(m.revokeDelay):
+		case <-m.ctx.Done():
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(m.ctx, revokeTimeout)
+		defer cancel()
+		if err := m.replaceLease(ctx, leaseID); err != nil {
+			// the new secret is fine, the replaced one will expire with its lease
+			m.events.report(component, path, err)
+			return
+		}
+		m.events.resolved(component, path)
+	}()
+}
+
+// renewLease asks Vault to extend the lease with the given ID by increment seconds. The returned secret only describes
+// the lease, Vault may grant less than the increment if the lease is about to reach its max TTL.
+func renewLease(ctx context.Context, leaseID string, increment int, vaultAddress, token string, client *http.Client, l *logging.Logger) (*secret, error) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	_, span := tracer.Start(
+		ctx,
+		"hashivault.renewLease",
+		trace.WithAttributes(attribute.String("lease_id", leaseID), attribute.Int("increment", increment)))
+	defer span.End()
+
+	req, err := writeReq(makeURL(vaultAddress, "sys/leases/renew"), token, map[string]any{
+		"lease_id":  leaseID,
+		"increment": increment,
+	})
+	if err != nil {
+		traceError(span, err, l)
+		return nil, err
+	}
+
+	sec, err := send(req.WithContext(ctx), "sys/leases/renew", client)
+	if err != nil {
+		traceError(span, err, l)
+		return nil, err
+	}
+
+	l.With(logging.RequestID(sec.RequestID), logging.LeaseDuration(time.Duration(sec.LeaseDuration)*time.Second)).
+		Printf("renewed lease %s, new lease duration %d", leaseID, sec.LeaseDuration)
+	return sec, nil
+}
+
+// revokeLease revokes the lease with the given ID, which invalidates the credentials it belongs to.
+func revokeLease(ctx context.Context, leaseID, vaultAddress, token string, client *http.Client, l *logging.Logger) error {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	_, span := tracer.Start(
+		ctx,
+		"hashivault.revokeLease",
+		trace.WithAttributes(attribute.String("lease_id", leaseID)))
+	defer span.End()
+
+	req, err := writeReq(makeURL(vaultAddress, "sys/leases/revoke"), token, map[string]any{"lease_id": leaseID})
+	if err != nil {
+		traceError(span, err, l)
+		return err
+	}
+
+	if _, err := send(req.WithContext(ctx), "sys/leases/revoke", client); err != nil {
+		traceError(span, err, l)
+		return err
+	}
+
+	l.Printf("revoked lease %s", leaseID)
+	return nil
+}
@@ -0,0 +1,218 @@
+package hashivault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_evergreenSecret_renewLease(t *testing.T) {
+	mux := &sync.Mutex{}
+	var requests []string
+	renewals := 0
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+
+		mux.Lock()
+		defer mux.Unlock()
+		requests = append(requests, r.URL.Path+" "+fmt.Sprint(body["lease_id"])+" "+fmt.Sprint(body["increment"]))
+
+		switch r.URL.Path {
+		case "/v1/database/creds/app":
+			id, username, ttl := "database/creds/app/1", "v-app-1", 2
+			if renewals > 0 {
+				id, username, ttl = "database/creds/app/2", "v-app-2", 3600
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"lease_id":       id,
+				"renewable":      true,
+				"lease_duration": ttl,
+				"data":           map[string]any{"username": username},
+			})
+		case "/v1/sys/leases/renew":
+			renewals++
+			// the second renewal is cut short by the max TTL of the lease
+			ttl := 2
+			if renewals == 2 {
+				ttl = 1
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"lease_id":       body["lease_id"],
+				"renewable":      true,
+				"lease_duration": ttl,
+			})
+		case "/v1/sys/leases/revoke":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	m.renewal = RenewalPolicy{Fraction: 0.5}
+	m.revokeOnClose = true
+
+	s, err := m.Secret(context.Background(), "database/creds/app")
+	NoErr(t, err)
+	changed := make(chan struct{}, 1)
+	s.es.subscribe(changed)
+
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("secret was not rotated")
+	}
+
+	data, info, err := s.Get(context.Background())
+	NoErr(t, err)
+	if data["username"] != "v-app-2" || info.Expires.Sub(info.Refreshed) != time.Hour {
+		t.Errorf("expected the rotated secret, got: %v %+v", data, info)
+	}
+
+	NoErr(t, m.Close())
+
+	mux.Lock()
+	defer mux.Unlock()
+	expected := []string{
+		"/v1/database/creds/app <nil> <nil>",
+		"/v1/sys/leases/renew database/creds/app/1 2",
+		"/v1/sys/leases/renew database/creds/app/1 2",
+		"/v1/database/creds/app <nil> <nil>",
+		"/v1/sys/leases/revoke database/creds/app/2 <nil>",
+		"/v1/sys/leases/revoke database/creds/app/1 <nil>",
+	}
+	if len(requests) != len(expected) {
+		t.Fatalf("expected requests %v, got %v", expected, requests)
+	}
+	for i := range expected {
+		if requests[i] != expected[i] {
+			t.Errorf("expected request %q, got %q", expected[i], requests[i])
+		}
+	}
+}
+
+func Test_evergreenSecret_renewLease_failed(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		wantIssued int
+	}{
+		{name: "server error", status: http.StatusInternalServerError, wantIssued: 1},
+		{name: "invalid lease", status: http.StatusBadRequest, wantIssued: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := &sync.Mutex{}
+			issued, renewals := 0, 0
+			var revoked []string
+			renewed := make(chan struct{}, 1)
+			m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+				var body map[string]any
+				json.NewDecoder(r.Body).Decode(&body)
+
+				mux.Lock()
+				defer mux.Unlock()
+				switch r.URL.Path {
+				case "/v1/database/creds/app":
+					issued++
+					json.NewEncoder(w).Encode(map[string]any{
+						"lease_id":       fmt.Sprintf("database/creds/app/%d", issued),
+						"renewable":      true,
+						"lease_duration": 6,
+						"data":           map[string]any{"username": fmt.Sprintf("v-app-%d", issued)},
+					})
+				case "/v1/sys/leases/renew":
+					renewals++
+					// the first renewal fails, the next ones succeed
+					if renewals == 1 {
+						w.WriteHeader(tt.status)
+						json.NewEncoder(w).Encode(map[string]any{"errors": []string{"renewal failed"}})
+						return
+					}
+					json.NewEncoder(w).Encode(map[string]any{
+						"lease_id":       body["lease_id"],
+						"renewable":      true,
+						"lease_duration": 6,
+					})
+					select {
+					case renewed <- struct{}{}:
+					default:
+					}
+				case "/v1/sys/leases/revoke":
+					revoked = append(revoked, fmt.Sprint(body["lease_id"]))
+					w.WriteHeader(http.StatusNoContent)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			})
+			m.renewal = RenewalPolicy{Fraction: 0.2}
+			m.revokeDelay = 0
+
+			_, err := m.Secret(context.Background(), "database/creds/app")
+			NoErr(t, err)
+
+			select {
+			case <-renewed:
+			case <-time.After(10 * time.Second):
+				t.Fatal("lease was not renewed after the failed renewal")
+			}
+
+			mux.Lock()
+			defer mux.Unlock()
+			if issued != tt.wantIssued {
+				t.Errorf("expected %d secrets to be issued, got %d", tt.wantIssued, issued)
+			}
+			if len(revoked) != 0 {
+				t.Errorf("expected no leases to be revoked, got: %v", revoked)
+			}
+		})
+	}
+}
+
+func Test_Secret_Revoke(t *testing.T) {
+	mux := &sync.Mutex{}
+	var revoked []string
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/database/creds/app":
+			json.NewEncoder(w).Encode(map[string]any{
+				"lease_id":       "database/creds/app/1",
+				"renewable":      true,
+				"lease_duration": 3600,
+				"data":           map[string]any{"username": "v-app-1"},
+			})
+		case "/v1/sys/leases/revoke":
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			mux.Lock()
+			revoked = append(revoked, fmt.Sprint(body["lease_id"]))
+			mux.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	m.revokeOnClose = true
+
+	s, err := m.Secret(context.Background(), "database/creds/app")
+	NoErr(t, err)
+	NoErr(t, s.Revoke(context.Background()))
+
+	// the revoked secret is no longer kept by the manager, so it is not revoked again on Close
+	if len(m.secrets) != 0 {
+		t.Errorf("expected the revoked secret to be removed, got %d secrets", len(m.secrets))
+	}
+	NoErr(t, m.Close())
+
+	mux.Lock()
+	defer mux.Unlock()
+	if len(revoked) != 1 || revoked[0] != "database/creds/app/1" {
+		t.Errorf("expected the lease to be revoked once, got: %v", revoked)
+	}
+	if data, _, _ := s.Get(context.Background()); data["username"] != "v-app-1" {
+		t.Errorf("expected the last data after Revoke, got: %v", data)
+	}
+}
@@ -1,35 +1,80 @@
 package hashivault
 
 import (
+	"bytes"
 	"context"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/3lvia/hashivault-go/internal/logging"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 	"io"
-	"log"
 	"net/http"
 	"os"
+	"strings"
+	"sync"
+	"time"
 )
 
-func newManager(vaultAddress string, tokenGetter tokenGetterFunc, errChan chan<- error, l *log.Logger) *manager {
-	return &manager{
+func newManager(vaultAddress string, tokenGetter tokenGetterFunc, errChan chan<- error, l *logging.Logger) *manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &manager{
 		vaultAddress: vaultAddress,
 		client:       &http.Client{},
 		tokenGetter:  tokenGetter,
-		errChan:      errChan,
+		events:       newEvents(errChan, l),
+		renewal:      DefaultRenewalPolicy,
+		secrets:      map[*evergreenSecret]struct{}{},
+		leases:       map[string]struct{}{},
+		googleCreds:  map[string]context.CancelFunc{},
+		revokeDelay:  replacedLeaseDelay,
+		ctx:          ctx,
+		cancel:       cancel,
+		mux:          &sync.Mutex{},
 		l:            l,
 	}
+	m.registerGauges(meter())
+	return m
 }
 
 type manager struct {
 	vaultAddress string
 	client       *http.Client
 	tokenGetter  tokenGetterFunc
-	errChan      chan<- error
-	l            *log.Logger
+	events       *events
+
+	// renewal decides when the leases of secrets are renewed, unless overridden for a secret.
+	renewal RenewalPolicy
+
+	// ctx is done when the manager is closed, which stops all background jobs.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// googleCredentialsDir is the directory where Google credentials are written, a temporary directory if empty.
+	googleCredentialsDir string
+
+	// files are the files and directories written by the manager, removed in reverse order on Close. googleCreds stops
+	// the job that rewrites the Google credentials from a path, keyed by the path.
+	mux         *sync.Mutex
+	files       []string
+	googleCreds map[string]context.CancelFunc
+
+	// secrets are the secrets kept up to date by the manager until they are revoked, and leases are the leases of other
+	// secrets the manager has handed out. Both are revoked on Close if revokeOnClose is set. The leases of replaced
+	// secrets are revoked after revokeDelay.
+	secrets       map[*evergreenSecret]struct{}
+	leases        map[string]struct{}
+	revokeOnClose bool
+	revokeDelay   time.Duration
+
+	// tokenExpires returns when the token expires, zero if unknown, and gauges reports it and the leases of secrets.
+	tokenExpires func() time.Time
+	gauges       metric.Registration
+
+	l *logging.Logger
 }
 
 func (m *manager) GetSecret(ctx context.Context, path string) (EvergreenSecretsFunc, error) {
@@ -40,58 +85,78 @@ func (m *manager) GetSecret(ctx context.Context, path string) (EvergreenSecretsF
 		trace.WithAttributes(attribute.String("path", path)))
 	defer span.End()
 
-	m.l.Printf("getting secrets from %s", path)
+	m.l.With(logging.Path(path)).Printf("getting secrets from %s", path)
 
-	sec, err := get(spanCtx, path, m.vaultAddress, m.tokenGetter(), m.client, m.l)
+	sec, es, err := m.getSecret(spanCtx, path, m.renewal)
 	if err != nil {
 		return nil, err
 	}
-
-	if !sec.Renewable {
+	if es == nil {
 		return sec.data, nil
 	}
-
-	es := newEvergreen(path, m.vaultAddress, sec, m.tokenGetter, m.client, m.errChan, m.l)
 	return es.get, nil
 }
 
-func (m *manager) SetDefaultGoogleCredentials(ctx context.Context, path, key string) error {
-	m.l.Print("setting default google credentials")
-
-	s, err := m.GetSecret(ctx, path)
+// getSecret fetches the secret at the given path. If the secret is renewable, an evergreen secret that keeps it up to
+// date according to the given policy is also returned, otherwise the returned evergreen secret is nil.
+func (m *manager) getSecret(ctx context.Context, path string, renewal RenewalPolicy) (*secret, *evergreenSecret, error) {
+	sec, err := m.read(ctx, path)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	sm := s()
-	if _, ok := sm[key]; !ok {
-		return fmt.Errorf("key %s not found in secret", key)
-	}
-	var encoded string
-	var ok bool
-	if encoded, ok = sm[key].(string); !ok {
-		return fmt.Errorf("key %s is not a string", key)
-	}
-	decoded, err := base64.StdEncoding.DecodeString(encoded)
-	if err != nil {
-		return err
+	if !sec.Renewable {
+		return sec, nil, nil
 	}
 
-	fn := "google-credentials.json"
-	if err := os.WriteFile(fn, decoded, 0644); err != nil {
-		return err
-	}
+	retire := func(leaseID string) { m.retireLease(ComponentSecret, path, leaseID) }
+	es := newEvergreen(m.ctx, path, m.vaultAddress, sec, m.tokenGetter, m.client, renewal, retire, m.events, m.l)
+	m.mux.Lock()
+	m.secrets[es] = struct{}{}
+	m.mux.Unlock()
+	return sec, es, nil
+}
 
-	if err := os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", fn); err != nil {
-		return err
+// read fetches the secret at the given path once, without keeping it up to date.
+func (m *manager) read(ctx context.Context, path string) (*secret, error) {
+	sec, err := get(ctx, path, m.vaultAddress, m.tokenGetter(), m.client, m.l)
+	recordRead(ctx, path, err)
+	return sec, err
+}
+
+func (m *manager) EventStats() EventStats {
+	return m.events.stats()
+}
+
+func (m *manager) Close() error {
+	m.l.Print("closing hashivault secrets manager")
+	m.cancel()
+
+	var errs []error
+	if m.revokeOnClose {
+		errs = m.revokeLeases()
 	}
 
-	m.l.Printf("set default google credentials to %s", fn)
+	m.mux.Lock()
+	defer m.mux.Unlock()
 
-	return nil
+	if m.gauges != nil {
+		if err := m.gauges.Unregister(); err != nil {
+			errs = append(errs, err)
+		}
+		m.gauges = nil
+	}
+	for i := len(m.files) - 1; i >= 0; i-- {
+		if err := os.Remove(m.files[i]); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+	m.files = nil
+
+	return errors.Join(errs...)
 }
 
-func get(ctx context.Context, path, vaultAddress, token string, client *http.Client, l *log.Logger) (*secret, error) {
+func get(ctx context.Context, path, vaultAddress, token string, client *http.Client, l *logging.Logger) (_ *secret, err error) {
 	tracer := otel.GetTracerProvider().Tracer(tracerName)
 	_, span := tracer.Start(
 		ctx,
@@ -100,8 +165,14 @@ func get(ctx context.Context, path, vaultAddress, token string, client *http.Cli
 	defer span.End()
 
 	url := makeURL(vaultAddress, path)
+	l = l.With(logging.Path(path))
 	l.Printf("getting secrets from %s", url)
 
+	start := time.Now()
+	defer func() {
+		recordRequest(ctx, "read", start, err)
+	}()
+
 	req, err := secretsReq(url, token)
 	if err != nil {
 		traceError(span, err, l)
@@ -114,6 +185,7 @@ func get(ctx context.Context, path, vaultAddress, token string, client *http.Cli
 		return nil, err
 	}
 	defer resp.Body.Close()
+	l = l.With(logging.Status(resp.StatusCode))
 
 	// Read the response body
 	body, err := io.ReadAll(resp.Body)
@@ -122,6 +194,12 @@ func get(ctx context.Context, path, vaultAddress, token string, client *http.Cli
 		return nil, err
 	}
 
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := &statusError{path: path, code: resp.StatusCode, errors: vaultErrors(body)}
+		traceError(span, err, l)
+		return nil, err
+	}
+
 	var sec secret
 	err = json.Unmarshal(body, &sec)
 	if err != nil {
@@ -129,10 +207,96 @@ func get(ctx context.Context, path, vaultAddress, token string, client *http.Cli
 		return nil, err
 	}
 
-	l.Printf("got secrets from %s", url)
+	l.With(logging.RequestID(sec.RequestID), logging.LeaseDuration(time.Duration(sec.LeaseDuration)*time.Second)).
+		Printf("got secrets from %s", url)
+	return &sec, nil
+}
+
+// write sends data to the given path with a POST request and returns the response. This is used by the secrets
+// engines that issue credentials based on request parameters, such as the SSH engine.
+func write(ctx context.Context, path, vaultAddress, token string, data any, client *http.Client, l *logging.Logger) (*secret, error) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(
+		ctx,
+		"hashivault.write",
+		trace.WithAttributes(attribute.String("path", path), attribute.String("vaultAddress", vaultAddress)))
+	defer span.End()
+
+	url := makeURL(vaultAddress, path)
+	l = l.With(logging.Path(path))
+	l.Printf("writing to %s", url)
+
+	req, err := writeReq(url, token, data)
+	if err != nil {
+		traceError(span, err, l)
+		return nil, err
+	}
+
+	sec, err := send(req.WithContext(spanCtx), path, client)
+	if err != nil {
+		traceError(span, err, l)
+		return nil, err
+	}
+
+	l.With(logging.RequestID(sec.RequestID)).Printf("wrote to %s", url)
+	return sec, nil
+}
+
+// send sends a request that writes to Vault, and parses the response, if any.
+func send(req *http.Request, path string, client *http.Client) (_ *secret, err error) {
+	start := time.Now()
+	defer func() {
+		recordRequest(req.Context(), "write", start, err)
+	}()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &statusError{path: path, code: resp.StatusCode, errors: vaultErrors(body)}
+	}
+
+	var sec secret
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &sec); err != nil {
+			return nil, err
+		}
+	}
+
 	return &sec, nil
 }
 
+// statusError is returned when Vault responds to a request for path with an unexpected status code.
+type statusError struct {
+	path   string
+	code   int
+	errors string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status code from %s: %d%s", e.path, e.code, e.errors)
+}
+
+// vaultErrors returns the errors in a Vault error response formatted for appending to an error message, or the
+// empty string if there are none.
+func vaultErrors(body []byte) string {
+	var resp struct {
+		Errors []string `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || len(resp.Errors) == 0 {
+		return ""
+	}
+	return ": " + strings.Join(resp.Errors, "; ")
+}
+
 // makeURL returns a correctly formatted url for Vault http requests
 func makeURL(address, path string) string {
 	return address + "/v1/" + path
@@ -149,3 +313,21 @@ func secretsReq(url, auth string) (*http.Request, error) {
 
 	return req, nil
 }
+
+// writeReq returns a http request for writing data to Vault
+func writeReq(url, auth string, data any) (*http.Request, error) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("while marshaling request body: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(js))
+	if err != nil {
+		return nil, fmt.Errorf("while building http request: %w", err)
+	}
+
+	req.Header.Set("X-Vault-Token", auth)
+	req.Header.Set("Content-Type", "application/json")
+
+	return req, nil
+}
@@ -1,41 +1,178 @@
 package hashivault
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/3lvia/hashivault-go/internal/logging"
+	"log/slog"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
+const googleCredentialsJSON = `{"type": "service_account", "project_id": "my-project"}`
+
 func Test_manager_SetDefaultGoogleCredentials(t *testing.T) {
-	type fields struct {
-		vaultAddress string
-		client       *http.Client
-		tokenGetter  tokenGetterFunc
-		errChan      chan<- error
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"renewable":      false,
+			"lease_duration": 0,
+			"data": map[string]any{
+				"data": map[string]any{
+					"raw":     googleCredentialsJSON,
+					"encoded": base64.StdEncoding.EncodeToString([]byte(googleCredentialsJSON)),
+					"number":  42,
+					"garbage": "not base64!",
+				},
+				"metadata": map[string]any{"version": 1},
+			},
+		})
 	}
+
 	type args struct {
 		path string
 		key  string
 	}
 	tests := []struct {
 		name    string
-		fields  fields
 		args    args
 		wantErr bool
 	}{
-		// TODO: Add test cases.
+		{name: "raw json", args: args{path: "gcp/kv/data/credentials", key: "raw"}},
+		{name: "base64", args: args{path: "gcp/kv/data/credentials", key: "encoded"}},
+		{name: "missing key", args: args{path: "gcp/kv/data/credentials", key: "missing"}, wantErr: true},
+		{name: "not a string", args: args{path: "gcp/kv/data/credentials", key: "number"}, wantErr: true},
+		{name: "not json or base64", args: args{path: "gcp/kv/data/credentials", key: "garbage"}, wantErr: true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			m := &manager{
-				vaultAddress: tt.fields.vaultAddress,
-				client:       tt.fields.client,
-				tokenGetter:  tt.fields.tokenGetter,
-				errChan:      tt.fields.errChan,
+			t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+
+			dir := t.TempDir()
+			m := newTestManager(t, handler)
+			m.googleCredentialsDir = dir
+
+			fn, err := m.SetDefaultGoogleCredentials(context.Background(), tt.args.path, tt.args.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetDefaultGoogleCredentials() error = %v, wantErr %v", err, tt.wantErr)
 			}
-			if err := m.SetDefaultGoogleCredentials(context.Background(), tt.args.path, tt.args.key); (err != nil) != tt.wantErr {
-				t.Errorf("SetDefaultGoogleCredentials() error = %v, wantErr %v", err, tt.wantErr)
+			if tt.wantErr {
+				return
+			}
+
+			if want := filepath.Join(dir, googleCredentialsFile); fn != want {
+				t.Errorf("unexpected path, got: %s, want: %s", fn, want)
+			}
+			if env := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); env != fn {
+				t.Errorf("unexpected GOOGLE_APPLICATION_CREDENTIALS, got: %s", env)
+			}
+			assertFile(t, fn, googleCredentialsJSON)
+
+			fi, err := os.Stat(fn)
+			NoErr(t, err)
+			if fi.Mode().Perm() != 0600 {
+				t.Errorf("unexpected permissions, got: %s", fi.Mode().Perm())
 			}
+
+			NoErr(t, m.Close())
+			if _, err := os.Stat(fn); !os.IsNotExist(err) {
+				t.Errorf("expected %s to be removed on Close", fn)
+			}
+		})
+	}
+}
+
+func Test_manager_SetDefaultGoogleCredentials_tempDir(t *testing.T) {
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{
+				"data":     map[string]any{"raw": googleCredentialsJSON},
+				"metadata": map[string]any{"version": 1},
+			},
+		})
+	})
+
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+
+	fn, err := m.SetDefaultGoogleCredentials(context.Background(), "gcp/kv/data/credentials", "raw")
+	NoErr(t, err)
+	assertFile(t, fn, googleCredentialsJSON)
+
+	NoErr(t, m.Close())
+	if _, err := os.Stat(filepath.Dir(fn)); !os.IsNotExist(err) {
+		t.Errorf("expected temporary directory %s to be removed on Close", filepath.Dir(fn))
+	}
+}
+
+func Test_manager_SetDefaultGoogleCredentials_again(t *testing.T) {
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"lease_id":       "gcp/kv/credentials/abc",
+			"renewable":      true,
+			"lease_duration": 3600,
+			"data":           map[string]any{"raw": googleCredentialsJSON},
 		})
+	})
+	m.googleCredentialsDir = t.TempDir()
+
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+
+	for i := 0; i < 2; i++ {
+		_, err := m.SetDefaultGoogleCredentials(context.Background(), "gcp/kv/credentials", "raw")
+		NoErr(t, err)
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if len(m.secrets) != 1 || len(m.googleCreds) != 1 {
+		t.Errorf("expected the credentials to be kept up to date once, got %d secrets", len(m.secrets))
+	}
+}
+
+func Test_manager_GetSecret_structuredLogging(t *testing.T) {
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"request_id":     "req-1",
+			"renewable":      false,
+			"lease_duration": 600,
+			"data":           map[string]any{"password": "s3cr3t-value"},
+		})
+	})
+	var buf bytes.Buffer
+	m.l = logging.New(nil, slog.New(slog.NewJSONHandler(&buf, nil)))
+	m.tokenGetter = func() string { return "s3cr3t-token" }
+
+	_, err := m.GetSecret(context.Background(), "kunde/kv/data/db")
+	NoErr(t, err)
+
+	if strings.Contains(buf.String(), "s3cr3t") {
+		t.Fatalf("expected no token or secret values in the log, got: %s", buf.String())
+	}
+
+	var got map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		NoErr(t, json.Unmarshal([]byte(line), &record))
+		if strings.HasPrefix(record["msg"].(string), "got secrets from") {
+			got = record
+		}
+	}
+	if got == nil {
+		t.Fatalf("expected a record for the secret read, got: %s", buf.String())
+	}
+	expected := map[string]any{
+		logging.KeyPath:          "kunde/kv/data/db",
+		logging.KeyStatus:        float64(http.StatusOK),
+		logging.KeyRequestID:     "req-1",
+		logging.KeyLeaseDuration: float64(600),
+	}
+	for k, v := range expected {
+		if got[k] != v {
+			t.Errorf("expected %s to be %v, got %v", k, v, got[k])
+		}
 	}
 }
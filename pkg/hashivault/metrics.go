@@ -0,0 +1,168 @@
+package hashivault
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"sync"
+	"time"
+)
+
+const (
+	metricLogins          = "hashivault.logins"
+	metricReads           = "hashivault.secret.reads"
+	metricRefreshes       = "hashivault.secret.refreshes"
+	metricRequestDuration = "hashivault.request.duration"
+	metricTokenTTL        = "hashivault.token.ttl"
+	metricLeaseTTL        = "hashivault.secret.lease.ttl"
+	metricEvergreen       = "hashivault.secrets.evergreen"
+)
+
+// meter returns the meter of the package, which has the same name as the tracer.
+func meter() metric.Meter {
+	return otel.GetMeterProvider().Meter(tracerName)
+}
+
+// outcome returns the value of the outcome attribute of a measurement.
+func outcome(err error) attribute.KeyValue {
+	if err != nil {
+		return attribute.String("outcome", "failure")
+	}
+	return attribute.String("outcome", "success")
+}
+
+// instruments are the counters and the histogram of the package. They are created once, on first use, from the meter
+// provider configured at that time.
+var instruments struct {
+	once      sync.Once
+	logins    metric.Int64Counter
+	reads     metric.Int64Counter
+	refreshes metric.Int64Counter
+	requests  metric.Float64Histogram
+}
+
+// loadInstruments creates the instruments of the package if they have not been created yet. Instruments that cannot be
+// created are replaced by instruments that record nothing.
+func loadInstruments() {
+	instruments.once.Do(func() {
+		mt := meter()
+		instruments.logins = counter(mt, metricLogins, "Number of logins to Vault.")
+		instruments.reads = counter(mt, metricReads, "Number of secrets read from Vault.")
+		instruments.refreshes = counter(mt, metricRefreshes, "Number of refreshes of secrets kept up to date.")
+
+		histogram, err := mt.Float64Histogram(metricRequestDuration,
+			metric.WithDescription("Latency of requests to Vault."),
+			metric.WithUnit("s"))
+		if err != nil {
+			otel.Handle(err)
+			histogram = noop.Float64Histogram{}
+		}
+		instruments.requests = histogram
+	})
+}
+
+func counter(mt metric.Meter, name, description string) metric.Int64Counter {
+	c, err := mt.Int64Counter(name, metric.WithDescription(description))
+	if err != nil {
+		otel.Handle(err)
+		return noop.Int64Counter{}
+	}
+	return c
+}
+
+// recordLogin counts a login to Vault with the given authentication method.
+func recordLogin(ctx context.Context, method string, err error) {
+	loadInstruments()
+	instruments.logins.Add(ctx, 1, metric.WithAttributes(attribute.String("method", method), outcome(err)))
+}
+
+// recordRead counts a read of the secret at the given path.
+func recordRead(ctx context.Context, path string, err error) {
+	loadInstruments()
+	instruments.reads.Add(ctx, 1, metric.WithAttributes(attribute.String("path", path), outcome(err)))
+}
+
+// recordRefresh counts a refresh of the secret at the given path, either by renewing its lease or by rotating it.
+func recordRefresh(ctx context.Context, path, kind string, err error) {
+	loadInstruments()
+	instruments.refreshes.Add(ctx, 1,
+		metric.WithAttributes(attribute.String("path", path), attribute.String("kind", kind), outcome(err)))
+}
+
+// recordRequest records the latency of a request to Vault that started at the given time.
+func recordRequest(ctx context.Context, operation string, start time.Time, err error) {
+	loadInstruments()
+	instruments.requests.Record(ctx, time.Since(start).Seconds(),
+		metric.WithAttributes(attribute.String("operation", operation), outcome(err)))
+}
+
+// registerGauges registers the gauges that report how long the token and the leases of the secrets kept up to date
+// by the manager are valid, so that credentials about to expire can be alerted on. They are unregistered by Close.
+func (m *manager) registerGauges(mt metric.Meter) {
+	tokenTTL, err := mt.Float64ObservableGauge(metricTokenTTL,
+		metric.WithDescription("Time until the Vault token expires."),
+		metric.WithUnit("s"))
+	if err != nil {
+		otel.Handle(err)
+		return
+	}
+	leaseTTL, err := mt.Float64ObservableGauge(metricLeaseTTL,
+		metric.WithDescription("Time until the lease of a secret kept up to date expires."),
+		metric.WithUnit("s"))
+	if err != nil {
+		otel.Handle(err)
+		return
+	}
+	evergreen, err := mt.Int64ObservableGauge(metricEvergreen,
+		metric.WithDescription("Number of secrets kept up to date."))
+	if err != nil {
+		otel.Handle(err)
+		return
+	}
+
+	reg, err := mt.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		m.mux.Lock()
+		tokenExpires := m.tokenExpires
+		secrets := make([]*evergreenSecret, 0, len(m.secrets))
+		for es := range m.secrets {
+			secrets = append(secrets, es)
+		}
+		m.mux.Unlock()
+
+		if tokenExpires != nil {
+			if expires := tokenExpires(); !expires.IsZero() {
+				o.ObserveFloat64(tokenTTL, time.Until(expires).Seconds())
+			}
+		}
+
+		// the same path may be kept up to date more than once, in which case the lease that expires first is reported
+		leases := map[string]time.Time{}
+		var active int64
+		for _, es := range secrets {
+			path, expires, ok := es.expires()
+			if !ok {
+				continue
+			}
+			active++
+			if e, seen := leases[path]; expires.IsZero() || seen && !e.After(expires) {
+				continue
+			}
+			leases[path] = expires
+		}
+		for path, expires := range leases {
+			o.ObserveFloat64(leaseTTL, time.Until(expires).Seconds(), metric.WithAttributes(attribute.String("path", path)))
+		}
+		o.ObserveInt64(evergreen, active)
+		return nil
+	}, tokenTTL, leaseTTL, evergreen)
+	if err != nil {
+		otel.Handle(err)
+		return
+	}
+
+	m.mux.Lock()
+	m.gauges = reg
+	m.mux.Unlock()
+}
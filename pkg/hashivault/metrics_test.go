@@ -0,0 +1,150 @@
+package hashivault
+
+import (
+	"context"
+	"encoding/json"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"math"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// metricReader reads the metrics recorded with the global meter provider. The provider is only set once, like in a
+// program, since the instruments of the package are created from the provider of the first recording.
+var metricReader = sync.OnceValue(func() sdkmetric.Reader {
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	return reader
+})
+
+func Test_metrics(t *testing.T) {
+	// the metrics are cumulative, so what this test records is the difference from the metrics recorded before it
+	before := collectMetrics(t, metricReader())
+
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/userpass/login/app":
+			json.NewEncoder(w).Encode(map[string]any{
+				"auth": map[string]any{"client_token": "token", "lease_duration": 600, "renewable": true},
+			})
+		case "/v1/auth/token/lookup-self":
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"ttl": 600}})
+		case "/v1/database/creds/metrics":
+			json.NewEncoder(w).Encode(map[string]any{
+				"lease_id":       "database/creds/metrics/1",
+				"renewable":      true,
+				"lease_duration": 3600,
+				"data":           map[string]any{"username": "v-app-1"},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	c := &optionsCollector{vaultAddress: m.vaultAddress, authenticator: userpass{user: "app"}}
+	j := newTokenJob(c, m.client, m.l)
+	ar, err := j.authenticate(context.Background())
+	NoErr(t, err)
+	j.schedule(ar)
+	m.tokenExpires = j.expires
+
+	// the gauges of the manager are read from a provider of their own, since managers of other tests report to the
+	// global one too
+	NoErr(t, m.gauges.Unregister())
+	gaugeReader := sdkmetric.NewManualReader()
+	m.registerGauges(sdkmetric.NewMeterProvider(sdkmetric.WithReader(gaugeReader)).Meter("test"))
+
+	_, err = m.Secret(context.Background(), "database/creds/metrics")
+	NoErr(t, err)
+	if _, err := m.Secret(context.Background(), "database/creds/missing"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	after := collectMetrics(t, metricReader())
+	delta := func(name string, attrs ...attribute.KeyValue) int64 {
+		b, _ := before[name].(metricdata.Sum[int64])
+		a, _ := after[name].(metricdata.Sum[int64])
+		return sumValue(a, attrs...) - sumValue(b, attrs...)
+	}
+
+	if v := delta(metricLogins, attribute.String("method", "Custom"), attribute.String("outcome", "success")); v != 1 {
+		t.Errorf("expected 1 login, got %d", v)
+	}
+	if v := delta(metricReads, attribute.String("path", "database/creds/metrics"), attribute.String("outcome", "success")); v != 1 {
+		t.Errorf("expected 1 successful read, got %d", v)
+	}
+	if v := delta(metricReads, attribute.String("path", "database/creds/missing"), attribute.String("outcome", "failure")); v != 1 {
+		t.Errorf("expected 1 failed read, got %d", v)
+	}
+
+	b, _ := before[metricRequestDuration].(metricdata.Histogram[float64])
+	a, _ := after[metricRequestDuration].(metricdata.Histogram[float64])
+	if v := histogramCount(a) - histogramCount(b); v != 3 {
+		t.Errorf("expected 1 login and 2 reads, got %d requests", v)
+	}
+
+	gauges := collectMetrics(t, gaugeReader)
+
+	tokenTTL, _ := gauges[metricTokenTTL].(metricdata.Gauge[float64])
+	if len(tokenTTL.DataPoints) != 1 || math.Abs(tokenTTL.DataPoints[0].Value-600) > 5 {
+		t.Errorf("expected a token ttl of 600s, got %+v", tokenTTL.DataPoints)
+	}
+
+	leaseTTL, _ := gauges[metricLeaseTTL].(metricdata.Gauge[float64])
+	if len(leaseTTL.DataPoints) != 1 || math.Abs(leaseTTL.DataPoints[0].Value-3600) > 5 {
+		t.Errorf("expected a lease ttl of 3600s, got %+v", leaseTTL.DataPoints)
+	} else if path, _ := leaseTTL.DataPoints[0].Attributes.Value("path"); path.AsString() != "database/creds/metrics" {
+		t.Errorf("unexpected path of lease ttl, got: %s", path.AsString())
+	}
+
+	evergreen, _ := gauges[metricEvergreen].(metricdata.Gauge[int64])
+	if len(evergreen.DataPoints) != 1 || evergreen.DataPoints[0].Value != 1 {
+		t.Errorf("expected 1 evergreen secret, got %+v", evergreen.DataPoints)
+	}
+
+	// the gauges of a closed manager are no longer reported
+	NoErr(t, m.Close())
+	for name, data := range collectMetrics(t, gaugeReader) {
+		if g, ok := data.(metricdata.Gauge[float64]); ok && len(g.DataPoints) > 0 {
+			t.Errorf("expected no gauges after Close, got %s: %+v", name, g.DataPoints)
+		}
+	}
+}
+
+// collectMetrics collects the metrics of the reader by name.
+func collectMetrics(t *testing.T, reader sdkmetric.Reader) map[string]metricdata.Aggregation {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	NoErr(t, reader.Collect(context.Background(), &rm))
+	metrics := map[string]metricdata.Aggregation{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, md := range sm.Metrics {
+			metrics[md.Name] = md.Data
+		}
+	}
+	return metrics
+}
+
+// histogramCount returns the number of measurements in all data points of the histogram.
+func histogramCount(h metricdata.Histogram[float64]) uint64 {
+	var n uint64
+	for _, dp := range h.DataPoints {
+		n += dp.Count
+	}
+	return n
+}
+
+// sumValue returns the value of the data point of the sum with exactly the given attributes.
+func sumValue(sum metricdata.Sum[int64], attrs ...attribute.KeyValue) int64 {
+	set := attribute.NewSet(attrs...)
+	for _, dp := range sum.DataPoints {
+		if dp.Attributes.Equals(&set) {
+			return dp.Value
+		}
+	}
+	return 0
+}
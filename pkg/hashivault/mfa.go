@@ -0,0 +1,36 @@
+package hashivault
+
+import (
+	"fmt"
+	"github.com/3lvia/hashivault-go/internal/auth"
+	"os"
+)
+
+// MFAProvider is called when a login requires MFA, once for each MFA method that must be completed. For methods that
+// use a passcode, such as TOTP, it returns the passcode. For methods that don't, such as Duo or Okta push, the login is
+// approved on another device, and the returned passcode is ignored.
+type MFAProvider = auth.MFAProvider
+
+// MFAMethod is an MFA method that can satisfy the MFA requirement of a login.
+type MFAMethod = auth.MFAMethod
+
+// WithMFAProvider sets the MFA provider that completes the MFA of logins that require it, with any authentication
+// method. PromptPasscode can be used to prompt for passcodes in the terminal.
+func WithMFAProvider(p MFAProvider) Option {
+	return func(o *optionsCollector) {
+		o.mfaProvider = p
+	}
+}
+
+// PromptPasscode reads the passcode of an MFA method, such as a TOTP code, from the terminal without echoing it. For
+// methods without a passcode, it asks the user to approve the login on their device instead. It can be used as an
+// MFAProvider.
+func PromptPasscode(method MFAMethod) (string, error) {
+	if !method.UsesPasscode {
+		fmt.Fprintf(os.Stderr, "Approve the login with %s MFA (%s) on your device\n", method.Type, method.Name)
+		return "", nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Passcode for %s MFA (%s): ", method.Type, method.Name)
+	return readSecret()
+}
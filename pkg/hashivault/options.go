@@ -3,9 +3,14 @@ package hashivault
 import (
 	"fmt"
 	"github.com/3lvia/hashivault-go/internal/auth"
+	"github.com/go-logr/logr"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type optionsCollector struct {
@@ -14,10 +19,37 @@ type optionsCollector struct {
 	gitHubToken    string
 	k8sMountPath   string
 	k8sRole        string
+	k8sTokenPath   string
+	k8sAudience    string
 	useOIDC        bool
+	noOIDCCache    bool
+	oidcHeadless   bool
+	oidcConfig     OIDCConfig
+	oidcAuthURL    func(authURL string)
 	vaultToken     string
+	wrappedToken   string
 	otelTracerName string
 	logger         *log.Logger
+	slogLogger     *slog.Logger
+	authenticator  Authenticator
+	authChain      []AuthMethod
+	passwordMethod auth.Method
+	password       PasswordConfig
+	mfaProvider    MFAProvider
+	errorHandler   func(Event)
+	renewalPolicy  *RenewalPolicy
+	revokeOnClose  bool
+
+	googleCredentialsDir string
+	wrappedTokenFile     string
+
+	// vaultTokenFile is a file with a token maintained by another process. If it was not configured, but found in the
+	// home directory, homeVaultTokenFile is true and the token is only used if it is valid.
+	vaultTokenFile     string
+	homeVaultTokenFile bool
+
+	// envSeen are the environment variables that were set when the options were built, for diagnostics.
+	envSeen []string
 }
 
 // Option is a function that can be used to configure this package.
@@ -37,6 +69,64 @@ func WithOIDC() Option {
 	}
 }
 
+// OIDCConfig configures the OIDC flow. Empty fields take the default values, which are the mount "oidc", the default
+// role of the mount, and a callback listening on localhost:8250.
+type OIDCConfig struct {
+	// Role is the OIDC role to log in with.
+	Role string
+
+	// Mount is the path where the OIDC auth method is mounted.
+	Mount string
+
+	// ListenAddress and Port is where the callback server listens.
+	ListenAddress string
+	Port          string
+
+	// CallbackHost is the host name in the redirect URI given to the OIDC provider.
+	CallbackHost string
+
+	// SkipBrowser prints the URL to complete the login at to stderr without opening the browser.
+	SkipBrowser bool
+
+	// Timeout is how long to wait for the user to complete the login, five minutes by default.
+	Timeout time.Duration
+}
+
+// WithOIDCConfig sets the authentication method to OIDC, configured by cfg.
+func WithOIDCConfig(cfg OIDCConfig) Option {
+	return func(o *optionsCollector) {
+		o.useOIDC = true
+		o.oidcConfig = cfg
+	}
+}
+
+// WithOIDCHeadless sets the authentication method to OIDC without a callback server on localhost, for remote VMs and
+// devcontainers where the browser cannot reach it. The URL to log in at is printed to stderr, and after logging in the
+// user pastes the URL the browser was redirected to (which fails to load) on stdin.
+func WithOIDCHeadless() Option {
+	return func(o *optionsCollector) {
+		o.useOIDC = true
+		o.oidcHeadless = true
+	}
+}
+
+// WithOIDCAuthURLHandler sets a function that receives the URL to complete the OIDC login at, instead of the URL being
+// opened in the browser and printed to stderr. This is useful for applications that present the URL themselves.
+func WithOIDCAuthURLHandler(handler func(authURL string)) Option {
+	return func(o *optionsCollector) {
+		o.oidcAuthURL = handler
+	}
+}
+
+// WithoutOIDCTokenCache disables caching of OIDC tokens. By default, the token from the OIDC flow is cached in the user
+// config dir and reused by later processes for as long as it is valid, so that the browser is not launched on every
+// start. The cached token is encrypted if the file ~/.hashivault-key exists.
+func WithoutOIDCTokenCache() Option {
+	return func(o *optionsCollector) {
+		o.noOIDCCache = true
+	}
+}
+
 // WithVaultToken sets the Vault token to use when authenticating to Vault.
 func WithVaultToken(token string) Option {
 	return func(o *optionsCollector) {
@@ -44,6 +134,32 @@ func WithVaultToken(token string) Option {
 	}
 }
 
+// WithVaultTokenFile sets the path of a file containing the Vault token, such as the sink file of a Vault Agent. The
+// file is re-read whenever it changes, so the process writing the file is responsible for renewing the token. If no
+// other authentication method or auth chain is configured, or only OIDC is, the token written by "vault login" to
+// ~/.vault-token is used if it is valid.
+func WithVaultTokenFile(path string) Option {
+	return func(o *optionsCollector) {
+		o.vaultTokenFile = path
+	}
+}
+
+// WithWrappedToken sets a response-wrapping token that is unwrapped to obtain the Vault token, e.g. one created by an
+// orchestrator with "vault token create -wrap-ttl=5m". The wrapping token can only be used once, so the unwrapped token
+// is renewed rather than obtained again.
+func WithWrappedToken(token string) Option {
+	return func(o *optionsCollector) {
+		o.wrappedToken = token
+	}
+}
+
+// WithWrappedTokenFile sets the path of a file containing a response-wrapping token, see WithWrappedToken.
+func WithWrappedTokenFile(path string) Option {
+	return func(o *optionsCollector) {
+		o.wrappedTokenFile = path
+	}
+}
+
 // WithVaultAddress sets the address of the Vault server to use when making requests to Vault.
 func WithVaultAddress(address string) Option {
 	return func(o *optionsCollector) {
@@ -66,6 +182,35 @@ func WithKubernetes(mountPath, role string) Option {
 	}
 }
 
+// KubernetesConfig configures Kubernetes authentication. The mount and role can also be set with the environment
+// variables MOUNT_PATH and ROLE, and the token path and audience with VAULT_K8S_TOKEN_PATH and VAULT_K8S_AUDIENCE.
+type KubernetesConfig struct {
+	// Mount is the path where the Kubernetes auth method is mounted, e.g. "kubernetes" or "auth/k8s-prod".
+	Mount string
+
+	// Role is the Vault role to log in with.
+	Role string
+
+	// TokenPath is the file with the service account token. If not set, the token that Kubernetes mounts in
+	// /var/run/secrets/kubernetes.io/serviceaccount is used. Projected tokens are mounted at the path given in the pod
+	// spec. The token is read on each login, so rotated tokens are picked up.
+	TokenPath string
+
+	// Audience is the audience the token must be issued for. If set, the login fails with a clear error if the token
+	// in TokenPath is issued for another audience.
+	Audience string
+}
+
+// WithKubernetesConfig sets the authentication method to Kubernetes, configured by cfg.
+func WithKubernetesConfig(cfg KubernetesConfig) Option {
+	return func(o *optionsCollector) {
+		o.k8sMountPath = cfg.Mount
+		o.k8sRole = cfg.Role
+		o.k8sTokenPath = cfg.TokenPath
+		o.k8sAudience = cfg.Audience
+	}
+}
+
 // WithOtelTracerName sets the name of the OpenTelemetry tracer to use when creating spans. If no name is set the
 // tracer name "go.opentelemetry.io/otel" is used.
 func WithOtelTracerName(name string) Option {
@@ -81,13 +226,52 @@ func WithLogger(logger *log.Logger) Option {
 	}
 }
 
+// WithSlogLogger sets a structured logger to use when logging. Besides the message, the records have fields with
+// fixed keys where they apply: component, path, method, status, request_id, lease_duration and error. Token and secret
+// values are never logged. It can be combined with WithLogger, in which case the messages are written to both.
+func WithSlogLogger(logger *slog.Logger) Option {
+	return func(o *optionsCollector) {
+		o.slogLogger = logger
+	}
+}
+
+// WithLogrLogger sets a logr logger to use when logging, with the same structured fields as WithSlogLogger.
+func WithLogrLogger(logger logr.Logger) Option {
+	return WithSlogLogger(slog.New(logr.ToSlogHandler(logger)))
+}
+
+// WithGoogleCredentialsDir sets the directory where SetDefaultGoogleCredentials writes the credentials file. If no
+// directory is set, a new temporary directory is used.
+func WithGoogleCredentialsDir(dir string) Option {
+	return func(o *optionsCollector) {
+		o.googleCredentialsDir = dir
+	}
+}
+
+// authMethod returns the authentication method to use when there is no auth chain. A wrapped token takes precedence
+// over a token file, since it is handed to the service for bootstrapping and can only be used once.
 func (c *optionsCollector) authMethod() auth.Method {
 	if c.vaultToken != "" {
 		return auth.MethodToken
 	}
+	if c.wrappedToken != "" {
+		return auth.MethodWrappedToken
+	}
+	if c.vaultTokenFile != "" {
+		return auth.MethodToken
+	}
+	if c.authenticator != nil {
+		return auth.MethodCustom
+	}
+	if c.passwordMethod != 0 {
+		return c.passwordMethod
+	}
 	if c.k8sMountPath != "" {
 		return auth.MethodK8s
 	}
+	if c.useOIDC && c.oidcHeadless {
+		return auth.MethodOICDHeadless
+	}
 	if c.useOIDC {
 		return auth.MethodOICD
 	}
@@ -95,36 +279,122 @@ func (c *optionsCollector) authMethod() auth.Method {
 	return auth.MethodGitHub
 }
 
+// renewal returns the renewal policy set with WithRenewalPolicy, or DefaultRenewalPolicy.
+func (c *optionsCollector) renewal() RenewalPolicy {
+	if c.renewalPolicy == nil {
+		return DefaultRenewalPolicy
+	}
+	return *c.renewalPolicy
+}
+
+// authOIDCConfig returns the OIDC configuration in the form used by the auth package.
+func (c *optionsCollector) authOIDCConfig() auth.OIDCConfig {
+	return auth.OIDCConfig{
+		Role:           c.oidcConfig.Role,
+		Mount:          c.oidcConfig.Mount,
+		ListenAddress:  c.oidcConfig.ListenAddress,
+		Port:           c.oidcConfig.Port,
+		CallbackHost:   c.oidcConfig.CallbackHost,
+		SkipBrowser:    c.oidcConfig.SkipBrowser,
+		Timeout:        c.oidcConfig.Timeout,
+		AuthURLHandler: c.oidcAuthURL,
+	}
+}
+
 func (c *optionsCollector) build() error {
-	va := os.Getenv("VAULT_ADDR")
+	va := c.getenv("VAULT_ADDR")
 	if va != "" {
 		c.vaultAddress = va
 	}
 
-	ght := os.Getenv("GITHUB_TOKEN")
+	ght := c.getenv("GITHUB_TOKEN")
 	if ght != "" {
 		c.gitHubToken = ght
 	}
 
-	k8sMP := os.Getenv("MOUNT_PATH")
+	k8sMP := c.getenv("MOUNT_PATH")
 	if k8sMP != "" {
 		c.k8sMountPath = k8sMP
 	}
 
-	k8sR := os.Getenv("ROLE")
+	k8sR := c.getenv("ROLE")
 	if k8sR != "" {
 		c.k8sRole = k8sR
 	}
 
-	vt := os.Getenv("VAULT_TOKEN")
+	k8sTP := c.getenv("VAULT_K8S_TOKEN_PATH")
+	if k8sTP != "" {
+		c.k8sTokenPath = k8sTP
+	}
+
+	k8sA := c.getenv("VAULT_K8S_AUDIENCE")
+	if k8sA != "" {
+		c.k8sAudience = k8sA
+	}
+
+	vt := c.getenv("VAULT_TOKEN")
 	if vt != "" {
 		c.vaultToken = vt
 	}
 
+	if headless, _ := strconv.ParseBool(c.getenv("VAULT_OIDC_HEADLESS")); headless {
+		c.useOIDC = true
+		c.oidcHeadless = true
+	}
+
+	vtf := c.getenv("VAULT_TOKEN_FILE")
+	if vtf != "" {
+		c.vaultTokenFile = vtf
+	}
+
+	wt := c.getenv("VAULT_WRAPPED_TOKEN")
+	if wt != "" {
+		c.wrappedToken = wt
+	}
+
+	un := c.getenv("VAULT_USERNAME")
+	if un != "" {
+		c.password.Username = un
+	}
+
+	pw := c.getenv("VAULT_PASSWORD")
+	if pw != "" {
+		c.password.Password = pw
+	}
+
+	wtf := c.getenv("VAULT_WRAPPED_TOKEN_FILE")
+	if wtf != "" {
+		c.wrappedTokenFile = wtf
+	}
+	if c.wrappedToken == "" && c.wrappedTokenFile != "" {
+		b, err := os.ReadFile(c.wrappedTokenFile)
+		if err != nil {
+			return fmt.Errorf("while reading wrapped token: %w", err)
+		}
+		c.wrappedToken = strings.TrimSpace(string(b))
+	}
+
+	if c.vaultTokenFile == "" && len(c.authChain) == 0 && c.vaultToken == "" && c.wrappedToken == "" && c.authenticator == nil && c.passwordMethod == 0 && c.k8sMountPath == "" && c.gitHubToken == "" {
+		c.vaultTokenFile = homeVaultTokenFile()
+		c.homeVaultTokenFile = c.vaultTokenFile != ""
+	}
+
 	if c.vaultAddress == "" {
 		return fmt.Errorf("VAULT_ADDR not set")
 	}
-	if c.vaultToken != "" {
+	return c.validateAuth()
+}
+
+// validateAuth checks that an authentication method is configured.
+func (c *optionsCollector) validateAuth() error {
+	if len(c.authChain) > 0 {
+		// methods that are not configured are skipped by the auth chain
+		return nil
+	}
+	if c.vaultToken != "" || c.vaultTokenFile != "" {
+		return nil
+	}
+	if c.wrappedToken != "" || c.authenticator != nil || c.passwordMethod != 0 {
 		return nil
 	}
 	if c.useOIDC {
@@ -135,3 +405,12 @@ func (c *optionsCollector) build() error {
 	}
 	return nil
 }
+
+// getenv returns the value of the environment variable, and records that it was seen if it is set.
+func (c *optionsCollector) getenv(name string) string {
+	v := os.Getenv(name)
+	if v != "" {
+		c.envSeen = append(c.envSeen, name)
+	}
+	return v
+}
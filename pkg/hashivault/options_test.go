@@ -1,7 +1,10 @@
 package hashivault
 
 import (
+	"context"
+	"github.com/3lvia/hashivault-go/internal/auth"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -106,6 +109,183 @@ func Test_optionsCollector_validate_FromEnvVars(t *testing.T) {
 	}
 }
 
+func Test_optionsCollector_validate_wrappedToken(t *testing.T) {
+	clearEnvVars(t)
+	if err := os.Setenv("VAULT_ADDR", "http://localhost:8200"); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(t.TempDir(), "wrapped-token")
+	if err := os.WriteFile(file, []byte("my-wrapped-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &optionsCollector{}
+	opt := WithWrappedTokenFile(file)
+	opt(c)
+
+	if err := c.build(); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.wrappedToken != "my-wrapped-token" {
+		t.Errorf("unexpected wrapped token, got: %s", c.wrappedToken)
+	}
+	if c.authMethod() != auth.MethodWrappedToken {
+		t.Errorf("unexpected auth method, got: %d", c.authMethod())
+	}
+}
+
+func Test_optionsCollector_validate_oidcConfig(t *testing.T) {
+	clearEnvVars(t)
+
+	var authURL string
+	c := &optionsCollector{}
+	for _, opt := range []Option{
+		WithVaultAddress("http://localhost:8200"),
+		WithOIDCConfig(OIDCConfig{Role: "developer", Port: "8251"}),
+		WithOIDCAuthURLHandler(func(u string) { authURL = u }),
+	} {
+		opt(c)
+	}
+
+	if err := c.build(); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.authMethod() != auth.MethodOICD {
+		t.Errorf("unexpected auth method, got: %d", c.authMethod())
+	}
+	cfg := c.authOIDCConfig()
+	if cfg.Role != "developer" || cfg.Port != "8251" || cfg.AuthURLHandler == nil {
+		t.Errorf("unexpected oidc config, got: %+v", cfg)
+	}
+	cfg.AuthURLHandler("https://login.example.com")
+	if authURL != "https://login.example.com" {
+		t.Errorf("unexpected auth url, got: %s", authURL)
+	}
+}
+
+func Test_optionsCollector_validate_oidcHeadless(t *testing.T) {
+	clearEnvVars(t)
+	t.Setenv("VAULT_ADDR", "http://localhost:8200")
+	t.Setenv("VAULT_OIDC_HEADLESS", "true")
+
+	c := &optionsCollector{}
+	if err := c.build(); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.authMethod() != auth.MethodOICDHeadless {
+		t.Errorf("unexpected auth method, got: %d", c.authMethod())
+	}
+}
+
+func Test_optionsCollector_validate_authenticator(t *testing.T) {
+	clearEnvVars(t)
+
+	c := &optionsCollector{}
+	for _, opt := range []Option{
+		WithVaultAddress("http://localhost:8200"),
+		WithOIDC(),
+		WithAuthenticator(userpass{user: "jane", password: "secret"}),
+	} {
+		opt(c)
+	}
+
+	if err := c.build(); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.authMethod() != auth.MethodCustom {
+		t.Errorf("unexpected auth method, got: %d", c.authMethod())
+	}
+}
+
+func Test_optionsCollector_validate_password(t *testing.T) {
+	clearEnvVars(t)
+	t.Setenv("VAULT_ADDR", "http://localhost:8200")
+	t.Setenv("VAULT_PASSWORD", "secret")
+
+	c := &optionsCollector{}
+	opt := WithLDAP(PasswordConfig{Username: "jane"})
+	opt(c)
+
+	if err := c.build(); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.authMethod() != auth.MethodLDAP {
+		t.Errorf("unexpected auth method, got: %d", c.authMethod())
+	}
+	if c.password.Username != "jane" || c.password.Password != "secret" {
+		t.Errorf("unexpected password config, got: %+v", c.password)
+	}
+}
+
+func Test_optionsCollector_validate_kubernetes(t *testing.T) {
+	clearEnvVars(t)
+	t.Setenv("VAULT_ADDR", "http://localhost:8200")
+	t.Setenv("MOUNT_PATH", "auth/k8s-prod")
+	t.Setenv("VAULT_K8S_TOKEN_PATH", "/var/run/secrets/tokens/vault")
+
+	c := &optionsCollector{}
+	opt := WithKubernetesConfig(KubernetesConfig{Role: "app", Audience: "vault"})
+	opt(c)
+
+	if err := c.build(); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.authMethod() != auth.MethodK8s {
+		t.Errorf("unexpected auth method, got: %d", c.authMethod())
+	}
+	if c.k8sMountPath != "auth/k8s-prod" || c.k8sRole != "app" || c.k8sTokenPath != "/var/run/secrets/tokens/vault" || c.k8sAudience != "vault" {
+		t.Errorf("unexpected kubernetes config, got: %s %s %s %s", c.k8sMountPath, c.k8sRole, c.k8sTokenPath, c.k8sAudience)
+	}
+}
+
+func Test_optionsCollector_authMethod(t *testing.T) {
+	tests := []struct {
+		name string
+		c    optionsCollector
+		want auth.Method
+	}{
+		{name: "nothing", want: auth.MethodGitHub},
+		{name: "token", c: optionsCollector{vaultToken: "s.token", wrappedToken: "s.wrapped"}, want: auth.MethodToken},
+		{name: "token file", c: optionsCollector{vaultTokenFile: "/vault/token"}, want: auth.MethodToken},
+		{
+			name: "wrapped token before token file",
+			c:    optionsCollector{vaultTokenFile: "/vault/token", wrappedToken: "s.wrapped"},
+			want: auth.MethodWrappedToken,
+		},
+		{
+			name: "wrapped token before authenticator",
+			c:    optionsCollector{wrappedToken: "s.wrapped", authenticator: userpass{}},
+			want: auth.MethodWrappedToken,
+		},
+		{name: "authenticator", c: optionsCollector{authenticator: userpass{}, k8sMountPath: "auth/k8s"}, want: auth.MethodCustom},
+		{name: "kubernetes", c: optionsCollector{k8sMountPath: "auth/k8s", useOIDC: true}, want: auth.MethodK8s},
+		{name: "oidc", c: optionsCollector{useOIDC: true}, want: auth.MethodOICD},
+		{name: "oidc headless", c: optionsCollector{useOIDC: true, oidcHeadless: true}, want: auth.MethodOICDHeadless},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.c.authMethod(); got != tt.want {
+				t.Errorf("unexpected auth method, got: %d, want: %d", got, tt.want)
+			}
+		})
+	}
+}
+
+type userpass struct {
+	user, password string
+}
+
+func (u userpass) Login(ctx context.Context, c *AuthClient) (AuthResult, error) {
+	return c.Login(ctx, "auth/userpass/login/"+u.user, map[string]string{"password": u.password})
+}
+
 func clearEnvVars(t *testing.T) {
 	if err := os.Unsetenv("VAULT_ADDR"); err != nil {
 		t.Fatal(err)
@@ -119,4 +299,31 @@ func clearEnvVars(t *testing.T) {
 	if err := os.Unsetenv("ROLE"); err != nil {
 		t.Fatal(err)
 	}
+	if err := os.Unsetenv("VAULT_WRAPPED_TOKEN"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Unsetenv("VAULT_WRAPPED_TOKEN_FILE"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Unsetenv("VAULT_TOKEN_FILE"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Unsetenv("VAULT_OIDC_HEADLESS"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Unsetenv("VAULT_K8S_TOKEN_PATH"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Unsetenv("VAULT_K8S_AUDIENCE"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Unsetenv("VAULT_USERNAME"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Unsetenv("VAULT_PASSWORD"); err != nil {
+		t.Fatal(err)
+	}
+
+	// ~/.vault-token is used when no other authentication method is configured
+	t.Setenv("HOME", t.TempDir())
 }
@@ -1,17 +1,17 @@
 package hashivault
 
 import (
+	"github.com/3lvia/hashivault-go/internal/logging"
 	"go.opentelemetry.io/otel/trace"
-	"log"
 )
 
 const defaultTracerName = "go.opentelemetry.io/otel"
 
 var tracerName string
 
-func traceError(span trace.Span, err error, l *log.Logger) {
+func traceError(span trace.Span, err error, l *logging.Logger) {
 	if err != nil {
-		l.Printf("error: %s", err.Error())
+		l.With(logging.Error(err)).Errorf("error: %s", err.Error())
 		span.RecordError(err)
 	}
 }
@@ -0,0 +1,61 @@
+package hashivault
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/3lvia/hashivault-go/internal/auth"
+	"golang.org/x/term"
+	"os"
+	"strings"
+)
+
+// PasswordConfig configures username and password authentication with the userpass or LDAP auth method. The username
+// and password can also be set with the environment variables VAULT_USERNAME and VAULT_PASSWORD. If no password is
+// given, it is obtained from PasswordPrompt, e.g. PromptPassword to read it from the terminal.
+type PasswordConfig = auth.PasswordConfig
+
+// WithUserpass sets the authentication method to userpass, configured by cfg.
+func WithUserpass(cfg PasswordConfig) Option {
+	return func(o *optionsCollector) {
+		o.passwordMethod = auth.MethodUserpass
+		o.password = cfg
+	}
+}
+
+// WithLDAP sets the authentication method to LDAP, configured by cfg.
+func WithLDAP(cfg PasswordConfig) Option {
+	return func(o *optionsCollector) {
+		o.passwordMethod = auth.MethodLDAP
+		o.password = cfg
+	}
+}
+
+// PromptPassword reads the password of the user from the terminal without echoing it. It can be used as
+// PasswordConfig.PasswordPrompt.
+func PromptPassword(username string) (string, error) {
+	fmt.Fprintf(os.Stderr, "Password for %s: ", username)
+	return readSecret()
+}
+
+// readSecret reads a line from stdin, without echo if stdin is a terminal.
+func readSecret() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("while reading from stdin: %w", err)
+		}
+		return strings.TrimSpace(line), nil
+	}
+
+	b, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("while reading from terminal: %w", err)
+	}
+	if len(b) == 0 {
+		return "", errors.New("nothing entered")
+	}
+	return string(b), nil
+}
@@ -0,0 +1,235 @@
+package hashivault
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/3lvia/hashivault-go/internal/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"os"
+	"os/exec"
+	"sync"
+	"text/template"
+)
+
+// RenderOption configures what happens when Render rewrites a file.
+type RenderOption func(*renderer)
+
+// WithReloadCommand sets a command that is run every time the file has been rewritten because a secret changed, e.g.
+// a command that tells a legacy application to reload its configuration. The command is not run after the initial
+// rendering.
+func WithReloadCommand(name string, args ...string) RenderOption {
+	return func(r *renderer) {
+		r.reloadCmd = append([]string{name}, args...)
+	}
+}
+
+// WithReloadSignal sets a signal that is sent to the process with the given pid every time the file has been rewritten
+// because a secret changed, e.g. syscall.SIGHUP. The signal is not sent after the initial rendering.
+func WithReloadSignal(pid int, sig os.Signal) RenderOption {
+	return func(r *renderer) {
+		r.reloadPid = pid
+		r.reloadSignal = sig
+	}
+}
+
+// renderer renders a template to a file, and keeps the file up to date as the referenced secrets change.
+type renderer struct {
+	m       *manager
+	tmpl    *template.Template
+	outPath string
+	perms   os.FileMode
+
+	reloadCmd    []string
+	reloadPid    int
+	reloadSignal os.Signal
+
+	// secrets holds the secrets referenced by the template, keyed by path. It is populated while rendering. evergreen
+	// are the secrets among them that are kept up to date, which is stopped when the file is no longer rendered.
+	mux       *sync.Mutex
+	secrets   map[string]EvergreenSecretsFunc
+	evergreen []*evergreenSecret
+	changed   chan struct{}
+	last      []byte
+}
+
+func (m *manager) Render(ctx context.Context, templateText, outPath string, perms os.FileMode, opts ...RenderOption) error {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(
+		ctx,
+		"hashivault.Render",
+		trace.WithAttributes(attribute.String("out_path", outPath)))
+	defer span.End()
+
+	m.l.With(logging.Path(outPath)).Printf("rendering template to %s", outPath)
+
+	r := &renderer{
+		m:       m,
+		outPath: outPath,
+		perms:   perms,
+		mux:     &sync.Mutex{},
+		secrets: map[string]EvergreenSecretsFunc{},
+		changed: make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	tmpl, err := template.New(outPath).Funcs(template.FuncMap{"secret": r.secretFunc(spanCtx)}).Parse(templateText)
+	if err != nil {
+		traceError(span, err, m.l)
+		return fmt.Errorf("while parsing template: %w", err)
+	}
+	r.tmpl = tmpl
+
+	if _, err := r.render(spanCtx); err != nil {
+		traceError(span, err, m.l)
+		r.stop()
+		return err
+	}
+
+	m.l.With(logging.Path(outPath)).Printf("rendered template to %s", outPath)
+
+	go r.start(ctx)
+
+	return nil
+}
+
+// secretFunc returns the template function {{ secret "path" "key" }}. Each path is fetched only once, the first time
+// it is referenced, after which the evergreen secret is used.
+func (r *renderer) secretFunc(ctx context.Context) func(path, key string) (any, error) {
+	return func(path, key string) (any, error) {
+		r.mux.Lock()
+		s, ok := r.secrets[path]
+		r.mux.Unlock()
+
+		if !ok {
+			sec, es, err := r.m.getSecret(ctx, path, r.m.renewal)
+			if err != nil {
+				return nil, err
+			}
+			s = sec.data
+			if es != nil {
+				es.subscribe(r.changed)
+				s = es.get
+			}
+
+			r.mux.Lock()
+			r.secrets[path] = s
+			if es != nil {
+				r.evergreen = append(r.evergreen, es)
+			}
+			r.mux.Unlock()
+		}
+
+		v, ok := s()[key]
+		if !ok {
+			return nil, fmt.Errorf("key %s not found in secret %s", key, path)
+		}
+		return v, nil
+	}
+}
+
+// render executes the template and writes the result to the file if it differs from what was last written. The
+// returned bool is true if the file was written.
+func (r *renderer) render(ctx context.Context) (bool, error) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	_, span := tracer.Start(
+		ctx,
+		"hashivault.renderer.render",
+		trace.WithAttributes(attribute.String("out_path", r.outPath)))
+	defer span.End()
+
+	var buf bytes.Buffer
+	if err := r.tmpl.Execute(&buf, nil); err != nil {
+		traceError(span, err, r.m.l)
+		return false, fmt.Errorf("while executing template: %w", err)
+	}
+
+	if r.last != nil && bytes.Equal(r.last, buf.Bytes()) {
+		return false, nil
+	}
+
+	if err := writeFileAtomic(r.outPath, buf.Bytes(), r.perms); err != nil {
+		traceError(span, err, r.m.l)
+		return false, fmt.Errorf("while writing %s: %w", r.outPath, err)
+	}
+	r.last = buf.Bytes()
+
+	return true, nil
+}
+
+// start re-renders the file every time one of the referenced secrets changes, until the context is done or the manager
+// is closed.
+func (r *renderer) start(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			r.m.l.Printf("stopped rendering template to %s", r.outPath)
+			r.stop()
+			return
+		case <-r.m.ctx.Done():
+			r.m.l.Printf("stopped rendering template to %s", r.outPath)
+			return
+		case <-r.changed:
+		}
+
+		r.m.l.Printf("secret changed, rendering template to %s", r.outPath)
+		written, err := r.render(ctx)
+		if err != nil {
+			r.m.events.report(ComponentRender, r.outPath, err)
+			continue
+		}
+		if !written {
+			r.m.events.resolved(ComponentRender, r.outPath)
+			continue
+		}
+
+		if err := r.reload(); err != nil {
+			r.m.events.report(ComponentRender, r.outPath, err)
+			continue
+		}
+		r.m.events.resolved(ComponentRender, r.outPath)
+	}
+}
+
+// stop stops keeping the secrets referenced by the template up to date. When the manager is closed, Close takes care
+// of them instead.
+func (r *renderer) stop() {
+	r.mux.Lock()
+	secrets := r.evergreen
+	r.evergreen = nil
+	r.mux.Unlock()
+
+	for _, es := range secrets {
+		if err := r.m.stopSecret(es); err != nil {
+			r.m.events.report(ComponentRender, r.outPath, err)
+		}
+	}
+}
+
+// reload runs the reload command and sends the reload signal, if configured.
+func (r *renderer) reload() error {
+	if len(r.reloadCmd) > 0 {
+		r.m.l.Printf("running reload command %s", r.reloadCmd[0])
+		out, err := exec.Command(r.reloadCmd[0], r.reloadCmd[1:]...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("while running reload command %s: %w: %s", r.reloadCmd[0], err, bytes.TrimSpace(out))
+		}
+	}
+
+	if r.reloadSignal != nil {
+		r.m.l.Printf("sending %s to process %d", r.reloadSignal, r.reloadPid)
+		p, err := os.FindProcess(r.reloadPid)
+		if err != nil {
+			return fmt.Errorf("while finding process %d: %w", r.reloadPid, err)
+		}
+		if err := p.Signal(r.reloadSignal); err != nil {
+			return fmt.Errorf("while sending %s to process %d: %w", r.reloadSignal, r.reloadPid, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package hashivault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func Test_manager_Render(t *testing.T) {
+	// the reload signal tells the test that the file has been re-rendered
+	reloaded := make(chan os.Signal, 1)
+	signal.Notify(reloaded, syscall.SIGUSR1)
+	defer signal.Stop(reloaded)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	secretCount := 0
+	countMux := &sync.Mutex{}
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/kunde/kv/data/appinsights/kunde":
+			fmt.Fprintln(w, jsonStaticSecret)
+		case "/v1/kunde/database/creds/kunde":
+			countMux.Lock()
+			secretCount++
+			n := secretCount
+			countMux.Unlock()
+			// the second secret is not rotated before the test ends, so the file is re-rendered once
+			ttl := 1
+			if n > 1 {
+				ttl = 3600
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"renewable":      true,
+				"lease_duration": ttl,
+				"data":           map[string]any{"instrumentation-key": fmt.Sprintf("secret-%d", n)},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	errChan := make(chan error, 10)
+	m.events = newEvents(errChan, m.l)
+
+	dir := t.TempDir()
+	outPath := filepath.Join(dir, "app.conf")
+	marker := filepath.Join(dir, "reloaded")
+	tmpl := `static={{ secret "kunde/kv/data/appinsights/kunde" "instrumentation-key" }}
+dynamic={{ secret "kunde/database/creds/kunde" "instrumentation-key" }}`
+
+	err := m.Render(ctx, tmpl, outPath, 0600,
+		WithReloadCommand("touch", marker), WithReloadSignal(os.Getpid(), syscall.SIGUSR1))
+	NoErr(t, err)
+
+	assertFile(t, outPath, "static=my-secret-instrumentation-key\ndynamic=secret-1")
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Error("expected reload command not to run after the initial rendering")
+	}
+
+	fi, err := os.Stat(outPath)
+	NoErr(t, err)
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("unexpected permissions, got: %s", fi.Mode().Perm())
+	}
+
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("file was not re-rendered")
+	}
+
+	assertFile(t, outPath, "static=my-secret-instrumentation-key\ndynamic=secret-2")
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("expected the reload command to run before the signal was sent, got: %v", err)
+	}
+
+	select {
+	case err := <-errChan:
+		t.Errorf("unexpected error: %v", err)
+	default:
+	}
+}
+
+func Test_manager_Render_missingKey(t *testing.T) {
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, jsonStaticSecret)
+	})
+
+	outPath := filepath.Join(t.TempDir(), "app.conf")
+	err := m.Render(context.Background(), `{{ secret "kunde/kv/data/appinsights/kunde" "nope" }}`, outPath, 0600)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := os.Stat(outPath); !os.IsNotExist(err) {
+		t.Error("expected no file to be written")
+	}
+}
+
+func Test_manager_Render_stop(t *testing.T) {
+	revoked := make(chan string, 1)
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/database/creds/app":
+			json.NewEncoder(w).Encode(map[string]any{
+				"lease_id":       "database/creds/app/1",
+				"renewable":      true,
+				"lease_duration": 3600,
+				"data":           map[string]any{"username": "v-app-1"},
+			})
+		case "/v1/sys/leases/revoke":
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			revoked <- fmt.Sprint(body["lease_id"])
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	m.revokeOnClose = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	outPath := filepath.Join(t.TempDir(), "app.conf")
+	NoErr(t, m.Render(ctx, `{{ secret "database/creds/app" "username" }}`, outPath, 0600))
+	assertFile(t, outPath, "v-app-1")
+
+	// the secret is no longer needed when the file is no longer rendered, so its lease is revoked without Close
+	cancel()
+	select {
+	case leaseID := <-revoked:
+		if leaseID != "database/creds/app/1" {
+			t.Errorf("unexpected lease revoked, got: %s", leaseID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the lease to be revoked when ctx was done")
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if len(m.secrets) != 0 {
+		t.Errorf("expected the secret to be forgotten, got %d secrets", len(m.secrets))
+	}
+}
+
+func assertFile(t *testing.T, name, want string) {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	NoErr(t, err)
+	if string(b) != want {
+		t.Errorf("unexpected content of %s, got: %q, want: %q", name, string(b), want)
+	}
+}
@@ -0,0 +1,88 @@
+package hashivault
+
+import (
+	"math/rand"
+	"time"
+)
+
+// minRenewalDelay is the shortest time between two renewals, so that very short leases do not cause busy loops.
+const minRenewalDelay = time.Second
+
+// expiredRetryDelay is how long to wait before retrying a failed renewal when the lease has already expired.
+const expiredRetryDelay = 5 * time.Second
+
+// RenewalPolicy decides when tokens and secret leases are renewed, which is well before they expire, so that callers
+// never get credentials that are about to be revoked.
+type RenewalPolicy struct {
+	// Fraction is the fraction of the lease that passes before it is renewed, e.g. 2/3 renews a lease of one hour
+	// after 40 minutes. If it is not between 0 and 1, the fraction of DefaultRenewalPolicy is used.
+	Fraction float64
+
+	// Jitter is the largest fraction of the delay that is randomly subtracted from it, so that many clients started
+	// together do not renew at the same time. Zero means no jitter.
+	Jitter float64
+
+	// MinGrace is the least time left of the lease when it is renewed. Leases that are not longer than MinGrace are
+	// renewed according to Fraction alone.
+	MinGrace time.Duration
+}
+
+// DefaultRenewalPolicy renews leases after 2/3 of their duration, with up to 10% jitter, and at least 30 seconds before
+// they expire.
+var DefaultRenewalPolicy = RenewalPolicy{
+	Fraction: 2.0 / 3.0,
+	Jitter:   0.1,
+	MinGrace: 30 * time.Second,
+}
+
+// WithRenewalPolicy sets when the token and the leases of the secrets are renewed, and when SSH certificates are
+// re-signed. The policy can be overridden for a single secret with WithSecretRenewalPolicy. If not set,
+// DefaultRenewalPolicy is used.
+func WithRenewalPolicy(p RenewalPolicy) Option {
+	return func(o *optionsCollector) {
+		o.renewalPolicy = &p
+	}
+}
+
+// WithSecretRenewalPolicy sets when the lease of the secret is renewed, overriding the policy of the SecretsManager.
+func WithSecretRenewalPolicy(p RenewalPolicy) SecretOption {
+	return func(s *Secret) {
+		s.renewal = p
+	}
+}
+
+func (p RenewalPolicy) fraction() float64 {
+	if p.Fraction <= 0 || p.Fraction > 1 {
+		return DefaultRenewalPolicy.Fraction
+	}
+	return p.Fraction
+}
+
+// delay returns how long to wait before renewing a lease of the given duration.
+func (p RenewalPolicy) delay(lease time.Duration) time.Duration {
+	d := time.Duration(float64(lease) * p.fraction())
+	if p.Jitter > 0 {
+		d -= time.Duration(rand.Float64() * p.Jitter * float64(d))
+	}
+	if lease > p.MinGrace && lease-d < p.MinGrace {
+		d = lease - p.MinGrace
+	}
+	if d < minRenewalDelay {
+		d = minRenewalDelay
+	}
+	return d
+}
+
+// retryDelay returns how long to wait before retrying a renewal that failed, given when the current lease expires. The
+// retries come closer together as the lease runs out.
+func (p RenewalPolicy) retryDelay(expires time.Time) time.Duration {
+	remaining := time.Until(expires)
+	if remaining <= 0 {
+		return expiredRetryDelay
+	}
+	d := time.Duration(float64(remaining) * p.fraction())
+	if d < minRenewalDelay {
+		d = minRenewalDelay
+	}
+	return d
+}
@@ -0,0 +1,109 @@
+package hashivault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRenewalPolicy_delay(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   RenewalPolicy
+		lease    time.Duration
+		min, max time.Duration
+	}{
+		{
+			name:   "fraction of lease",
+			policy: RenewalPolicy{Fraction: 0.5},
+			lease:  time.Hour,
+			min:    30 * time.Minute,
+			max:    30 * time.Minute,
+		},
+		{
+			name:   "jitter",
+			policy: RenewalPolicy{Fraction: 0.5, Jitter: 0.2},
+			lease:  time.Hour,
+			min:    24 * time.Minute,
+			max:    30 * time.Minute,
+		},
+		{
+			name:   "min grace",
+			policy: RenewalPolicy{Fraction: 0.9, MinGrace: 10 * time.Minute},
+			lease:  time.Hour,
+			min:    50 * time.Minute,
+			max:    50 * time.Minute,
+		},
+		{
+			name:   "lease shorter than grace",
+			policy: RenewalPolicy{Fraction: 0.5, MinGrace: time.Minute},
+			lease:  20 * time.Second,
+			min:    10 * time.Second,
+			max:    10 * time.Second,
+		},
+		{
+			name:   "default fraction",
+			policy: RenewalPolicy{},
+			lease:  3 * time.Hour,
+			min:    2 * time.Hour,
+			max:    2 * time.Hour,
+		},
+		{
+			name:   "minimum delay",
+			policy: DefaultRenewalPolicy,
+			lease:  0,
+			min:    minRenewalDelay,
+			max:    minRenewalDelay,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				d := tt.policy.delay(tt.lease)
+				if d < tt.min || d > tt.max {
+					t.Fatalf("expected delay between %s and %s, got %s", tt.min, tt.max, d)
+				}
+			}
+		})
+	}
+}
+
+func TestRenewalPolicy_retryDelay(t *testing.T) {
+	p := RenewalPolicy{Fraction: 0.5}
+
+	if d := p.retryDelay(time.Now().Add(-time.Second)); d != expiredRetryDelay {
+		t.Errorf("expected %s after the lease expired, got %s", expiredRetryDelay, d)
+	}
+	if d := p.retryDelay(time.Now().Add(time.Minute)); d > 30*time.Second || d < 29*time.Second {
+		t.Errorf("expected half the remaining lease, got %s", d)
+	}
+	if d := p.retryDelay(time.Now().Add(time.Second)); d != minRenewalDelay {
+		t.Errorf("expected %s, got %s", minRenewalDelay, d)
+	}
+}
+
+func Test_manager_Secret_renewalPolicy(t *testing.T) {
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"renewable":      true,
+			"lease_duration": 3600,
+			"data":           map[string]any{"username": "v-app-1"},
+		})
+	})
+	m.renewal = RenewalPolicy{Fraction: 0.5}
+
+	s, err := m.Secret(context.Background(), "database/creds/app")
+	NoErr(t, err)
+	if s.es.renewal != m.renewal {
+		t.Errorf("expected the policy of the manager, got %+v", s.es.renewal)
+	}
+
+	p := RenewalPolicy{Fraction: 0.8, Jitter: 0.05, MinGrace: time.Minute}
+	s, err = m.Secret(context.Background(), "database/creds/app", WithSecretRenewalPolicy(p))
+	NoErr(t, err)
+	if s.es.renewal != p {
+		t.Errorf("expected the policy of the secret, got %+v", s.es.renewal)
+	}
+}
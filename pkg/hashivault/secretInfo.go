@@ -0,0 +1,105 @@
+package hashivault
+
+import (
+	"context"
+	"github.com/3lvia/hashivault-go/internal/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+// SecretInfo describes how fresh the data of a secret is.
+type SecretInfo struct {
+	// Refreshed is when the data was fetched from Vault, or its lease was last renewed.
+	Refreshed time.Time
+
+	// Expires is when the lease of the data expires, zero if the secret has no lease.
+	Expires time.Time
+
+	// LastError is the error of the last refresh if it failed, in which case the data is from an earlier refresh.
+	LastError error
+
+	// FailedAttempts is the number of refreshes that have failed since the data was fetched.
+	FailedAttempts int
+}
+
+// Expired reports whether the lease of the data has expired, so that Vault may have revoked the credentials.
+func (i SecretInfo) Expired() bool {
+	return !i.Expires.IsZero() && time.Now().After(i.Expires)
+}
+
+// Stale reports whether the data may be outdated, because its lease has expired or the last refresh failed.
+func (i SecretInfo) Stale() bool {
+	return i.Expired() || i.LastError != nil
+}
+
+// SecretOption configures a Secret.
+type SecretOption func(*Secret)
+
+// Secret is a secret that is kept up to date by the SecretsManager, like the EvergreenSecretsFunc returned by
+// GetSecret. Unlike the func, Get also tells how fresh the data is.
+type Secret struct {
+	m       *manager
+	path    string
+	renewal RenewalPolicy
+
+	// es keeps the secret up to date, nil if the secret is not renewable, in which case sec and fetched are used.
+	es      *evergreenSecret
+	sec     *secret
+	fetched time.Time
+}
+
+// Path returns the path of the secret in Vault.
+func (s *Secret) Path() string {
+	return s.path
+}
+
+// Get returns the data of the secret, and information about how fresh it is. Callers that must not use stale
+// credentials should check the SecretInfo. If a refresh is in flight, Get waits for it to complete. If ctx is done
+// first, the current data is returned together with the error of ctx.
+func (s *Secret) Get(ctx context.Context) (map[string]any, SecretInfo, error) {
+	if s.es == nil {
+		info := SecretInfo{Refreshed: s.fetched}
+		if s.sec.LeaseDuration > 0 {
+			info.Expires = s.fetched.Add(time.Duration(s.sec.LeaseDuration) * time.Second)
+		}
+		return s.sec.data(), info, nil
+	}
+	return s.es.getCtx(ctx)
+}
+
+// Notify registers a channel that is signalled every time the data of the secret changes, e.g. when it is rotated.
+// The send is non-blocking, so the channel should be buffered, and a single signal may represent several changes. The
+// same channel may be registered with several secrets. If the secret is not renewable, its data never changes and
+// the channel is never signalled.
+func (s *Secret) Notify(ch chan<- struct{}) {
+	if s.es != nil {
+		s.es.subscribe(ch)
+	}
+}
+
+func (m *manager) Secret(ctx context.Context, path string, opts ...SecretOption) (*Secret, error) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(
+		ctx,
+		"hashivault.Secret",
+		trace.WithAttributes(attribute.String("path", path)))
+	defer span.End()
+
+	m.l.With(logging.Path(path)).Printf("getting secret from %s", path)
+
+	s := &Secret{m: m, path: path, renewal: m.renewal}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	sec, es, err := m.getSecret(spanCtx, path, s.renewal)
+	if err != nil {
+		traceError(span, err, m.l)
+		return nil, err
+	}
+
+	s.es, s.sec, s.fetched = es, sec, time.Now()
+	return s, nil
+}
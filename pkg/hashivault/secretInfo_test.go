@@ -0,0 +1,125 @@
+package hashivault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_manager_Secret(t *testing.T) {
+	var requests atomic.Int32
+	refreshing := make(chan struct{})
+	release := make(chan struct{})
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+		switch requests.Add(1) {
+		case 1:
+		case 2:
+			// the first refresh hangs until the test releases it, and then fails
+			close(refreshing)
+			<-release
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		default:
+			<-release
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"renewable":      true,
+			"lease_duration": 1,
+			"data":           map[string]any{"username": "v-app-1"},
+		})
+	})
+	// registered after the manager, so that the hanging requests are released before it is closed
+	t.Cleanup(func() { close(release) })
+
+	s, err := m.Secret(context.Background(), "database/creds/app")
+	NoErr(t, err)
+
+	data, info, err := s.Get(context.Background())
+	NoErr(t, err)
+	if data["username"] != "v-app-1" || info.Refreshed.IsZero() || info.Expires.Sub(info.Refreshed) != time.Second || info.Stale() {
+		t.Errorf("unexpected secret: %v %+v", data, info)
+	}
+
+	// wait for the refresh to start when the lease expires
+	select {
+	case <-refreshing:
+	case <-time.After(5 * time.Second):
+		t.Fatal("secret was not refreshed")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	data, _, err = s.Get(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || data["username"] != "v-app-1" {
+		t.Errorf("expected the last data and the error of ctx while refreshing, got: %v %v", data, err)
+	}
+
+	release <- struct{}{}
+	data, info, err = s.Get(context.Background())
+	NoErr(t, err)
+	if data["username"] != "v-app-1" || info.LastError == nil || info.FailedAttempts != 1 || !info.Stale() {
+		t.Errorf("expected stale data after the refresh failed, got: %v %+v", data, info)
+	}
+}
+
+func Test_manager_Secret_notRenewable(t *testing.T) {
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"renewable":      false,
+			"lease_duration": 1,
+			"data":           map[string]any{"username": "v-app-1"},
+		})
+	})
+
+	s, err := m.Secret(context.Background(), "database/creds/app")
+	NoErr(t, err)
+
+	data, info, err := s.Get(context.Background())
+	NoErr(t, err)
+	if data["username"] != "v-app-1" || info.Expires.Sub(info.Refreshed) != time.Second || info.Expired() {
+		t.Errorf("unexpected secret: %v %+v", data, info)
+	}
+
+	// the secret is not refreshed, so the data expires with its lease
+	<-time.After(time.Until(info.Expires) + 10*time.Millisecond)
+	if _, info, _ = s.Get(context.Background()); !info.Expired() || !info.Stale() {
+		t.Errorf("expected the secret to have expired, got: %+v", info)
+	}
+}
+
+func Test_Secret_Notify(t *testing.T) {
+	var requests atomic.Int32
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+		// without a lease ID, the secret is fetched again when its lease expires
+		json.NewEncoder(w).Encode(map[string]any{
+			"renewable":      true,
+			"lease_duration": 1,
+			"data":           map[string]any{"username": fmt.Sprintf("v-app-%d", requests.Add(1))},
+		})
+	})
+
+	s, err := m.Secret(context.Background(), "database/creds/app")
+	NoErr(t, err)
+
+	changed := make(chan struct{}, 1)
+	s.Notify(changed)
+
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a signal when the secret was rotated")
+	}
+	data, _, err := s.Get(context.Background())
+	NoErr(t, err)
+	if data["username"] == "v-app-1" {
+		t.Errorf("expected the rotated data, got: %v", data)
+	}
+}
@@ -0,0 +1,195 @@
+package hashivault
+
+import (
+	"context"
+	"fmt"
+	"github.com/3lvia/hashivault-go/internal/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SSHOption configures the certificates requested by SSHSigner.
+type SSHOption func(*sshSigner)
+
+// WithSSHPrincipals sets the principals (user names) the certificate is valid for. If not set, the default principals
+// of the role are used.
+func WithSSHPrincipals(principals ...string) SSHOption {
+	return func(s *sshSigner) {
+		s.principals = principals
+	}
+}
+
+// WithSSHTTL sets the requested lifetime of the certificate. If not set, the default TTL of the role is used.
+func WithSSHTTL(ttl time.Duration) SSHOption {
+	return func(s *sshSigner) {
+		s.ttl = ttl
+	}
+}
+
+func (m *manager) SSHSigner(ctx context.Context, path string, signer ssh.Signer, opts ...SSHOption) (ssh.Signer, error) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(
+		ctx,
+		"hashivault.SSHSigner",
+		trace.WithAttributes(attribute.String("path", path)))
+	defer span.End()
+
+	m.l.With(logging.Path(path)).Printf("getting ssh certificate from %s", path)
+
+	s := &sshSigner{
+		m:      m,
+		path:   path,
+		signer: signer,
+		mux:    &sync.Mutex{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if err := s.sign(spanCtx); err != nil {
+		traceError(span, err, m.l)
+		return nil, err
+	}
+
+	go s.start()
+
+	return s, nil
+}
+
+// sshSigner is an ssh.Signer that presents a certificate signed by the SSH secrets engine, and that has the
+// certificate re-signed before it expires.
+type sshSigner struct {
+	m          *manager
+	path       string
+	signer     ssh.Signer
+	principals []string
+	ttl        time.Duration
+
+	mux     *sync.Mutex
+	cert    *ssh.Certificate
+	current ssh.Signer
+}
+
+func (s *sshSigner) PublicKey() ssh.PublicKey {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.current.PublicKey()
+}
+
+func (s *sshSigner) Sign(rand io.Reader, data []byte) (*ssh.Signature, error) {
+	s.mux.Lock()
+	current := s.current
+	s.mux.Unlock()
+	return current.Sign(rand, data)
+}
+
+// SignWithAlgorithm implements ssh.AlgorithmSigner, which is needed for RSA keys to be used with SHA-2 signatures.
+func (s *sshSigner) SignWithAlgorithm(rand io.Reader, data []byte, algorithm string) (*ssh.Signature, error) {
+	s.mux.Lock()
+	current := s.current
+	s.mux.Unlock()
+
+	as, ok := current.(ssh.AlgorithmSigner)
+	if !ok {
+		return nil, fmt.Errorf("ssh: signer does not support algorithm %s", algorithm)
+	}
+	return as.SignWithAlgorithm(rand, data, algorithm)
+}
+
+// sign has the public key signed by Vault and replaces the current certificate.
+func (s *sshSigner) sign(ctx context.Context) error {
+	data := map[string]any{
+		"public_key": string(ssh.MarshalAuthorizedKey(s.signer.PublicKey())),
+		"cert_type":  "user",
+	}
+	if len(s.principals) > 0 {
+		data["valid_principals"] = strings.Join(s.principals, ",")
+	}
+	if s.ttl > 0 {
+		data["ttl"] = s.ttl.String()
+	}
+
+	sec, err := write(ctx, s.path, s.m.vaultAddress, s.m.tokenGetter(), data, s.m.client, s.m.l)
+	if err != nil {
+		return err
+	}
+
+	signed, _ := sec.data()["signed_key"].(string)
+	if signed == "" {
+		return fmt.Errorf("no signed key found in response from %s", s.path)
+	}
+
+	pk, _, _, _, err := ssh.ParseAuthorizedKey([]byte(signed))
+	if err != nil {
+		return fmt.Errorf("while parsing signed key from %s: %w", s.path, err)
+	}
+	cert, ok := pk.(*ssh.Certificate)
+	if !ok {
+		return fmt.Errorf("signed key from %s is not a certificate", s.path)
+	}
+
+	current, err := ssh.NewCertSigner(cert, s.signer)
+	if err != nil {
+		return err
+	}
+
+	s.mux.Lock()
+	s.cert = cert
+	s.current = current
+	s.mux.Unlock()
+
+	s.m.l.Printf("got ssh certificate with serial %d from %s, valid until %s", cert.Serial, s.path, validBefore(cert).Format(time.RFC3339))
+	return nil
+}
+
+// start re-signs the certificate before it expires, as decided by the renewal policy of the manager, until the manager
+// is closed. If signing fails, it is retried sooner and sooner as the current certificate runs out.
+func (s *sshSigner) start() {
+	var err error
+	for {
+		select {
+		case <-s.m.ctx.Done():
+			return
+		case <-time.After(s.nextSign(err)):
+		}
+
+		tracer := otel.GetTracerProvider().Tracer(tracerName)
+		ctx, span := tracer.Start(
+			s.m.ctx,
+			"hashivault.sshSigner.start",
+			trace.WithAttributes(attribute.String("path", s.path)))
+		err = s.sign(ctx)
+		span.End()
+		if err != nil {
+			s.m.events.report(ComponentSSH, s.path, err)
+			continue
+		}
+		s.m.events.resolved(ComponentSSH, s.path)
+	}
+}
+
+// nextSign returns how long to wait before re-signing the certificate, given the error of the last attempt.
+func (s *sshSigner) nextSign(lastErr error) time.Duration {
+	s.mux.Lock()
+	expires := validBefore(s.cert)
+	s.mux.Unlock()
+
+	if lastErr != nil {
+		return s.m.renewal.retryDelay(expires)
+	}
+	return s.m.renewal.delay(time.Until(expires))
+}
+
+// validBefore returns the time the certificate expires. Certificates valid forever are treated as valid for a year.
+func validBefore(cert *ssh.Certificate) time.Time {
+	if cert.ValidBefore == ssh.CertTimeInfinity {
+		return time.Now().Add(365 * 24 * time.Hour)
+	}
+	return time.Unix(int64(cert.ValidBefore), 0)
+}
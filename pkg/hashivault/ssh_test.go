@@ -0,0 +1,132 @@
+package hashivault
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"golang.org/x/crypto/ssh"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_manager_SSHSigner(t *testing.T) {
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	NoErr(t, err)
+	ca, err := ssh.NewSignerFromKey(caKey)
+	NoErr(t, err)
+
+	_, userKey, err := ed25519.GenerateKey(rand.Reader)
+	NoErr(t, err)
+	user, err := ssh.NewSignerFromKey(userKey)
+	NoErr(t, err)
+
+	tests := []struct {
+		name    string
+		path    string
+		opts    []SSHOption
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "principals",
+			path: "ssh/sign/deploy",
+			opts: []SSHOption{WithSSHPrincipals("deploy", "admin"), WithSSHTTL(time.Hour)},
+			want: []string{"deploy", "admin"},
+		},
+		{
+			name:    "unknown role",
+			path:    "ssh/sign/unknown",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/v1/ssh/sign/deploy" {
+					w.WriteHeader(http.StatusBadRequest)
+					json.NewEncoder(w).Encode(map[string]any{"errors": []string{"unknown role"}})
+					return
+				}
+
+				var req struct {
+					PublicKey       string `json:"public_key"`
+					CertType        string `json:"cert_type"`
+					ValidPrincipals string `json:"valid_principals"`
+					TTL             string `json:"ttl"`
+				}
+				NoErr(t, json.NewDecoder(r.Body).Decode(&req))
+				if req.CertType != "user" || req.TTL != "1h0m0s" {
+					t.Errorf("unexpected request, got: %+v", req)
+				}
+
+				pk, _, _, _, err := ssh.ParseAuthorizedKey([]byte(req.PublicKey))
+				NoErr(t, err)
+				cert := &ssh.Certificate{
+					Key:             pk,
+					Serial:          1,
+					CertType:        ssh.UserCert,
+					ValidPrincipals: []string{"deploy", "admin"},
+					ValidAfter:      uint64(time.Now().Add(-time.Minute).Unix()),
+					ValidBefore:     uint64(time.Now().Add(time.Hour).Unix()),
+				}
+				NoErr(t, cert.SignCert(rand.Reader, ca))
+
+				json.NewEncoder(w).Encode(map[string]any{
+					"data": map[string]any{
+						"serial_number": "1",
+						"signed_key":    string(ssh.MarshalAuthorizedKey(cert)),
+					},
+				})
+			})
+
+			signer, err := m.SSHSigner(context.Background(), tt.path, user, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SSHSigner() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			cert, ok := signer.PublicKey().(*ssh.Certificate)
+			if !ok {
+				t.Fatalf("expected a certificate, got: %T", signer.PublicKey())
+			}
+			checker := ssh.CertChecker{
+				IsUserAuthority: func(auth ssh.PublicKey) bool {
+					return string(auth.Marshal()) == string(ca.PublicKey().Marshal())
+				},
+			}
+			for _, principal := range tt.want {
+				NoErr(t, checker.CheckCert(principal, cert))
+			}
+
+			data := []byte("data")
+			sig, err := signer.Sign(rand.Reader, data)
+			NoErr(t, err)
+			NoErr(t, user.PublicKey().Verify(data, sig))
+		})
+	}
+}
+
+func Test_sshSigner_nextSign(t *testing.T) {
+	s := &sshSigner{
+		m:    &manager{renewal: RenewalPolicy{Fraction: 0.5}},
+		mux:  &sync.Mutex{},
+		cert: &ssh.Certificate{ValidBefore: uint64(time.Now().Add(time.Hour).Unix())},
+	}
+	if d := s.nextSign(nil); d < 29*time.Minute || d > 30*time.Minute {
+		t.Errorf("expected the certificate to be re-signed after half its lifetime, got %s", d)
+	}
+	if d := s.nextSign(errors.New("permission denied")); d < 29*time.Minute || d > 30*time.Minute {
+		t.Errorf("expected a retry after half the remaining lifetime, got %s", d)
+	}
+
+	s.cert.ValidBefore = ssh.CertTimeInfinity
+	if d := s.nextSign(nil); d < 182*24*time.Hour {
+		t.Errorf("expected certificates valid forever to be re-signed after half a year, got %s", d)
+	}
+}
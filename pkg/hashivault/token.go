@@ -3,93 +3,158 @@ package hashivault
 import (
 	"context"
 	"github.com/3lvia/hashivault-go/internal/auth"
+	"github.com/3lvia/hashivault-go/internal/logging"
 	"go.opentelemetry.io/otel"
-	"log"
 	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type tokenGetterFunc func() string
 
-func startTokenJob(ctx context.Context, c *optionsCollector, errChan chan<- error, initializedChan chan<- struct{}, client *http.Client, l *log.Logger) tokenGetterFunc {
-	if c.vaultToken != "" {
-		// If the token is already set, just return it
-		return func() string {
-			return c.vaultToken
-		}
-	}
+// startTokenJob starts a job that acquires a token and keeps it valid. Static tokens and token files are handled by
+// New, so they only reach the job as part of an auth chain.
+func startTokenJob(ctx context.Context, done <-chan struct{}, c *optionsCollector, ev *events, initializedChan chan<- struct{}, client *http.Client, l *logging.Logger) *tokenJob {
+	j := newTokenJob(c, client, l)
 
-	j := &tokenJob{
-		mux:          &sync.Mutex{},
-		vaultAddress: c.vaultAddress,
-		gitHubToken:  c.gitHubToken,
-		k8sMountPath: c.k8sMountPath,
-		k8sRole:      c.k8sRole,
-		client:       client,
-		method:       c.authMethod(),
-		l:            l,
-	}
+	go j.start(ctx, done, ev, initializedChan)
+	return j
+}
 
-	go j.start(ctx, errChan, initializedChan)
-	return j.token
+func newTokenJob(c *optionsCollector, client *http.Client, l *logging.Logger) *tokenJob {
+	return &tokenJob{
+		mux:           &sync.Mutex{},
+		vaultAddress:  c.vaultAddress,
+		gitHubToken:   c.gitHubToken,
+		k8sMountPath:  c.k8sMountPath,
+		k8sRole:       c.k8sRole,
+		k8sTokenPath:  c.k8sTokenPath,
+		k8sAudience:   c.k8sAudience,
+		wrappedToken:  c.wrappedToken,
+		oidcCache:     !c.noOIDCCache,
+		oidcConfig:    c.authOIDCConfig(),
+		authenticator: c.authenticator,
+		password:      c.password,
+		mfaProvider:   c.mfaProvider,
+		renewal:       c.renewal(),
+		client:        client,
+		method:        c.authMethod(),
+		chain:         c.chainLinks(),
+		chainEnv:      c.envSeen,
+		l:             l,
+	}
 }
 
 type tokenJob struct {
-	mux          *sync.Mutex
-	vaultAddress string
-	gitHubToken  string
-	k8sMountPath string
-	k8sRole      string
-	currentToken string
-	method       auth.Method
-	client       *http.Client
-	l            *log.Logger
+	mux           *sync.Mutex
+	vaultAddress  string
+	gitHubToken   string
+	k8sMountPath  string
+	k8sRole       string
+	k8sTokenPath  string
+	k8sAudience   string
+	wrappedToken  string
+	oidcCache     bool
+	oidcConfig    auth.OIDCConfig
+	authenticator Authenticator
+	password      PasswordConfig
+	mfaProvider   MFAProvider
+	renewal       RenewalPolicy
+	currentToken  string
+	expiresAt     atomic.Int64
+	method        auth.Method
+	chain         []chainLink
+	chainEnv      []string
+	tokenFile     *tokenFileJob
+	client        *http.Client
+	l             *logging.Logger
 }
 
-func (j *tokenJob) start(ctx context.Context, errChannel chan<- error, initializedChan chan<- struct{}) {
+// start acquires the first token, and then renews it before it expires, as decided by the renewal policy, until done
+// is closed. A renewal that fails is retried while the token is still valid.
+func (j *tokenJob) start(ctx context.Context, done <-chan struct{}, ev *events, initializedChan chan<- struct{}) {
 	j.l.Print("starting token job")
 
 	j.mux.Lock()
 	authResponse, err := j.authenticate(ctx)
 	if err != nil {
-		close(initializedChan)
-		errChannel <- err
 		j.mux.Unlock()
+		close(initializedChan)
+		ev.report(ComponentToken, "", err)
 		return
 	}
 	j.currentToken = authResponse.ClientToken()
 	j.mux.Unlock()
+	expires, after := j.schedule(authResponse)
 
 	// signal that we're done initializing
 	close(initializedChan)
 	j.l.Print("token job initialized, first token acquired")
 
+	if j.tokenFile != nil {
+		// the auth chain picked a token file, which is maintained by another process and re-read when it changes
+		j.tokenFile.start(done, ev)
+		return
+	}
+
 	if !authResponse.Renewable() {
 		// no need to renew token, so we're done
 		return
 	}
 
-	after := authResponse.After()
 	for {
-		<-after
+		select {
+		case <-after:
+		case <-done:
+			j.l.Print("token job stopped")
+			return
+		}
 		j.l.Print("renewing token")
 		j.mux.Lock()
-		ar, err := j.authenticate(context.Background())
+		ar, err := j.renew(context.Background())
 		if err != nil {
-			errChannel <- err
 			j.mux.Unlock()
+			ev.report(ComponentToken, "", err)
+			after = time.After(j.renewal.retryDelay(expires))
 			continue
 		}
+		ev.resolved(ComponentToken, "")
 		j.currentToken = ar.ClientToken()
-		after = ar.After()
+		expires, after = j.schedule(ar)
 		j.mux.Unlock()
 		j.l.Print("token renewed")
 	}
 }
 
+// schedule records when the token in the response expires, and returns it together with a channel that fires when the
+// token should be renewed. A token without a lease does not expire, so the channel never fires.
+func (j *tokenJob) schedule(ar auth.AuthenticationResponse) (time.Time, <-chan time.Time) {
+	lease := time.Duration(ar.LeaseDurationSeconds()) * time.Second
+	if lease == 0 {
+		j.expiresAt.Store(0)
+		return time.Time{}, nil
+	}
+	expires := time.Now().Add(lease)
+	j.expiresAt.Store(expires.UnixNano())
+	return expires, time.After(j.renewal.delay(lease))
+}
+
+// expires returns when the current token expires, zero if it does not expire or no token has been acquired yet.
+func (j *tokenJob) expires() time.Time {
+	n := j.expiresAt.Load()
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
 func (j *tokenJob) token() string {
 	j.mux.Lock()
 	defer j.mux.Unlock()
+	if j.tokenFile != nil {
+		return j.tokenFile.token()
+	}
 	return j.currentToken
 }
 
@@ -98,13 +163,62 @@ func (j *tokenJob) authenticate(ctx context.Context) (auth.AuthenticationRespons
 	spanCtx, span := tracer.Start(ctx, "hashivault.tokenJob.authenticate")
 	defer span.End()
 
-	return auth.Authenticate(
-		spanCtx,
-		j.vaultAddress,
-		j.method,
+	start := time.Now()
+	if len(j.chain) > 0 {
+		ar, err := j.authenticateChain(spanCtx)
+		traceError(span, err, j.l)
+		j.recordLogin(spanCtx, start, err)
+		return ar, err
+	}
+
+	ar, err := auth.Authenticate(spanCtx, j.vaultAddress, j.method, j.options()...)
+	j.recordLogin(spanCtx, start, err)
+	return ar, err
+}
+
+// recordLogin records a login that started at the given time. A failed auth chain has no single method, so it is
+// recorded as "chain".
+func (j *tokenJob) recordLogin(ctx context.Context, start time.Time, err error) {
+	method := j.method.String()
+	if len(j.chain) > 0 {
+		method = "chain"
+	}
+	recordLogin(ctx, method, err)
+	recordRequest(ctx, "login", start, err)
+}
+
+// options returns the options for the auth package.
+func (j *tokenJob) options() []auth.Option {
+	return []auth.Option{
 		auth.WithClient(j.client),
 		auth.WithLogger(j.l),
 		auth.WithGitHubToken(j.gitHubToken),
-		auth.WithK8s(j.k8sMountPath, j.k8sRole),
-		auth.WithOtelTracerName(tracerName))
+		auth.WithK8sConfig(auth.K8sConfig{
+			Mount:     j.k8sMountPath,
+			Role:      j.k8sRole,
+			TokenPath: j.k8sTokenPath,
+			Audience:  j.k8sAudience,
+		}),
+		auth.WithWrappedToken(j.wrappedToken),
+		auth.WithOIDCCache(j.oidcCache),
+		auth.WithOIDCConfig(j.oidcConfig),
+		auth.WithAuthenticator(j.authenticator),
+		auth.WithPassword(j.password),
+		auth.WithMFAProvider(j.mfaProvider),
+		auth.WithOtelTracerName(tracerName),
+	}
+}
+
+// renew returns a new token when the current one is about to expire. Tokens from authenticators that can renew them,
+// such as unwrapped tokens that can only be unwrapped once, are renewed. Otherwise, a new token is obtained by
+// authenticating again.
+func (j *tokenJob) renew(ctx context.Context) (auth.AuthenticationResponse, error) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(ctx, "hashivault.tokenJob.renew")
+	defer span.End()
+
+	start := time.Now()
+	ar, err := auth.Renew(spanCtx, j.vaultAddress, j.method, j.currentToken, j.options()...)
+	j.recordLogin(spanCtx, start, err)
+	return ar, err
 }
@@ -0,0 +1,143 @@
+package hashivault
+
+import (
+	"context"
+	"fmt"
+	"github.com/3lvia/hashivault-go/internal/auth"
+	"github.com/3lvia/hashivault-go/internal/logging"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenFilePollInterval is how often token files are checked for changes.
+const tokenFilePollInterval = 5 * time.Second
+
+// vaultTokenHelperFile is the file in the home directory where "vault login" stores the token.
+const vaultTokenHelperFile = ".vault-token"
+
+// homeVaultTokenFile returns the path of ~/.vault-token if it exists, otherwise the empty string.
+func homeVaultTokenFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	path := filepath.Join(home, vaultTokenHelperFile)
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// tokenFileJob serves the token in a file that is maintained by another process, such as "vault login" or a Vault
+// Agent sink. The file is re-read whenever it is modified, so the other process is responsible for renewing the token.
+type tokenFileJob struct {
+	path     string
+	interval time.Duration
+
+	mux          *sync.Mutex
+	currentToken string
+	modTime      time.Time
+
+	l *logging.Logger
+}
+
+// newTokenFileJob reads the token in the file at path. An error is returned if the file cannot be read or is empty.
+func newTokenFileJob(path string, l *logging.Logger) (*tokenFileJob, error) {
+	j := &tokenFileJob{
+		path:     path,
+		interval: tokenFilePollInterval,
+		mux:      &sync.Mutex{},
+		l:        l,
+	}
+	if _, err := j.read(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// start re-reads the token whenever the file is modified, until done is closed.
+func (j *tokenFileJob) start(done <-chan struct{}, ev *events) {
+	j.l.With(logging.Path(j.path)).Printf("watching token file %s", j.path)
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			j.l.Print("token file job stopped")
+			return
+		case <-ticker.C:
+		}
+
+		changed, err := j.read()
+		if err != nil {
+			ev.report(ComponentTokenFile, j.path, err)
+			continue
+		}
+		ev.resolved(ComponentTokenFile, j.path)
+		if changed {
+			j.l.With(logging.Path(j.path)).Printf("token in %s changed", j.path)
+		}
+	}
+}
+
+// read reads the token if the file has been modified since it was last read, and reports whether it was.
+func (j *tokenFileJob) read() (bool, error) {
+	info, err := os.Stat(j.path)
+	if err != nil {
+		return false, fmt.Errorf("while reading vault token file: %w", err)
+	}
+
+	j.mux.Lock()
+	defer j.mux.Unlock()
+
+	if info.ModTime().Equal(j.modTime) {
+		return false, nil
+	}
+
+	b, err := os.ReadFile(j.path)
+	if err != nil {
+		return false, fmt.Errorf("while reading vault token file: %w", err)
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return false, fmt.Errorf("vault token file %s is empty", j.path)
+	}
+
+	j.modTime = info.ModTime()
+	changed := token != j.currentToken
+	j.currentToken = token
+	return changed, nil
+}
+
+func (j *tokenFileJob) token() string {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	return j.currentToken
+}
+
+// checkHomeVaultTokenFile validates the token in ~/.vault-token if it was found rather than configured. The token
+// written by "vault login" may well have expired, in which case the other configured authentication methods are used,
+// and an error is returned if there are none.
+func (c *optionsCollector) checkHomeVaultTokenFile(ctx context.Context, client *http.Client, l *logging.Logger) error {
+	if !c.homeVaultTokenFile {
+		return nil
+	}
+
+	j, err := newTokenFileJob(c.vaultTokenFile, l)
+	if err == nil {
+		_, err = auth.LookupSelf(ctx, &auth.Client{Address: c.vaultAddress, HTTPClient: client}, j.token())
+	}
+	if err != nil {
+		l.With(logging.Path(c.vaultTokenFile), logging.Error(err)).Printf("not using vault token from %s: %s", c.vaultTokenFile, err)
+		c.vaultTokenFile = ""
+		c.homeVaultTokenFile = false
+		return c.validateAuth()
+	}
+	return nil
+}
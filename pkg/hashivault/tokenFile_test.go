@@ -0,0 +1,170 @@
+package hashivault
+
+import (
+	"context"
+	"errors"
+	"github.com/3lvia/hashivault-go/internal/auth"
+	"github.com/3lvia/hashivault-go/internal/logging"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_tokenFileJob(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sink")
+	NoErr(t, os.WriteFile(path, []byte("token-1\n"), 0600))
+
+	l := logging.New(nil, nil)
+	j, err := newTokenFileJob(path, l)
+	NoErr(t, err)
+	j.interval = 10 * time.Millisecond
+
+	if j.token() != "token-1" {
+		t.Errorf("unexpected token, got: %s", j.token())
+	}
+
+	// make sure the modification time changes on file systems with coarse timestamps
+	NoErr(t, os.WriteFile(path, []byte("token-2\n"), 0600))
+	NoErr(t, os.Chtimes(path, time.Now().Add(time.Second), time.Now().Add(time.Second)))
+
+	changed, err := j.read()
+	NoErr(t, err)
+	if !changed || j.token() != "token-2" {
+		t.Errorf("expected the token to be re-read, got: %t %s", changed, j.token())
+	}
+
+	errChan := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go j.start(done, newEvents(errChan, l))
+
+	// the job keeps the last token when the file goes away
+	NoErr(t, os.Remove(path))
+	select {
+	case err := <-errChan:
+		var ev Event
+		if !errors.As(err, &ev) || ev.Component != ComponentTokenFile || ev.Path != path {
+			t.Errorf("unexpected event, got: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected an event when the token file was removed")
+	}
+	if j.token() != "token-2" {
+		t.Errorf("expected the last token to be kept, got: %s", j.token())
+	}
+}
+
+func Test_newTokenFileJob_empty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sink")
+	NoErr(t, os.WriteFile(path, []byte("\n"), 0600))
+
+	if _, err := newTokenFileJob(path, logging.New(nil, nil)); err == nil {
+		t.Error("expected error for empty token file")
+	}
+}
+
+func Test_optionsCollector_checkHomeVaultTokenFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		token       string
+		opts        []Option
+		wantIgnored bool
+		wantMethod  auth.Method
+		wantErr     string
+	}{
+		{
+			name:       "valid token",
+			token:      "valid-token",
+			wantMethod: auth.MethodToken,
+		},
+		{
+			name:        "expired token falls back to oidc",
+			token:       "expired-token",
+			opts:        []Option{WithOIDC()},
+			wantIgnored: true,
+			wantMethod:  auth.MethodOICD,
+		},
+		{
+			name:        "valid token with an auth chain",
+			token:       "valid-token",
+			opts:        []Option{WithAuthChain(AuthTokenFile, AuthOIDC)},
+			wantIgnored: true,
+		},
+		{
+			name:        "expired token is the only source",
+			token:       "expired-token",
+			wantIgnored: true,
+			wantErr:     "GITHUB_TOKEN or MOUNT_PATH not set",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnvVars(t)
+
+			server := newLookupSelfServer(t)
+
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			NoErr(t, os.WriteFile(filepath.Join(home, ".vault-token"), []byte(tt.token), 0600))
+
+			c := &optionsCollector{}
+			for _, opt := range append(tt.opts, WithVaultAddress(server.URL)) {
+				opt(c)
+			}
+			NoErr(t, c.build())
+
+			err := c.checkHomeVaultTokenFile(context.Background(), server.Client(), logging.New(nil, nil))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got: %v", tt.wantErr, err)
+				}
+			} else {
+				NoErr(t, err)
+			}
+
+			if (c.vaultTokenFile == "") != tt.wantIgnored {
+				t.Fatalf("unexpected token file, got: %q, wantIgnored %v", c.vaultTokenFile, tt.wantIgnored)
+			}
+			if tt.wantErr == "" && tt.wantMethod != 0 && c.authMethod() != tt.wantMethod {
+				t.Errorf("unexpected auth method, got: %d", c.authMethod())
+			}
+		})
+	}
+}
+
+func TestNew_expiredHomeVaultToken(t *testing.T) {
+	clearEnvVars(t)
+
+	server := newLookupSelfServer(t)
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	NoErr(t, os.WriteFile(filepath.Join(home, ".vault-token"), []byte("expired-token"), 0600))
+
+	if _, _, err := New(context.Background(), WithVaultAddress(server.URL), WithClient(server.Client())); err == nil ||
+		!strings.Contains(err.Error(), "GITHUB_TOKEN or MOUNT_PATH not set") {
+		t.Errorf("expected New to fail without other authentication methods, got: %v", err)
+	}
+	if _, err := Login(context.Background(), WithVaultAddress(server.URL), WithClient(server.Client())); err == nil ||
+		!strings.Contains(err.Error(), "GITHUB_TOKEN or MOUNT_PATH not set") {
+		t.Errorf("expected Login to fail without other authentication methods, got: %v", err)
+	}
+}
+
+// newLookupSelfServer starts a test server that only accepts the token "valid-token" for looking itself up.
+func newLookupSelfServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "valid-token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"ttl":3600}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
@@ -1,6 +1,13 @@
 package hashivault
 
-import "context"
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/oauth2"
+	"os"
+	"time"
+)
 
 // SecretsManager represents a service that is able to provide clients with a secrets identified by paths.
 type SecretsManager interface {
@@ -10,10 +17,81 @@ type SecretsManager interface {
 	// safe to use concurrently.
 	GetSecret(ctx context.Context, path string) (EvergreenSecretsFunc, error)
 
+	// Secret fetches the secret at the given path and keeps it up to date like GetSecret. The returned Secret also
+	// tells when the data was last refreshed, when it expires and whether refreshing it has failed, so that callers
+	// can reject stale credentials. The lease of the secret is renewed according to the RenewalPolicy of the
+	// SecretsManager, unless another one is given with WithSecretRenewalPolicy.
+	Secret(ctx context.Context, path string, opts ...SecretOption) (*Secret, error)
+
 	// SetDefaultGoogleCredentials fetches the Google credentials from the given path and key and sets them as the
 	// default credentials for the current process. This means saving the credentials to disk and setting the
-	// environment variable GOOGLE_APPLICATION_CREDENTIALS to point to the saved file.
-	SetDefaultGoogleCredentials(ctx context.Context, path, key string) error
+	// environment variable GOOGLE_APPLICATION_CREDENTIALS to point to the saved file, whose path is returned. The
+	// credentials may be stored either as raw JSON or base64 encoded. The file is only readable by the current user,
+	// it is written to the directory set with WithGoogleCredentialsDir (or a temporary directory), it is rewritten
+	// when the secret changes and it is removed by Close. If it is called again for the same path, only the latest file
+	// is rewritten.
+	SetDefaultGoogleCredentials(ctx context.Context, path, key string) (string, error)
+
+	// ExportEnv fetches the secret at the given path and sets its keys as environment variables in the current process,
+	// named according to the given mapping. This is useful for SDKs that can only be configured via environment
+	// variables. The values are exported once, i.e. they are not updated if the secret is renewed.
+	ExportEnv(ctx context.Context, path string, mapping EnvMapping) error
+
+	// Render renders the given text/template to the file at outPath with the given permissions. Secrets are available
+	// in the template through the function secret, e.g. {{ secret "kunde/kv/data/db" "password" }}. The file is
+	// written atomically, and it is re-rendered every time one of the referenced secrets changes until ctx is done or
+	// the SecretsManager is closed. When ctx is done, the referenced secrets are no longer kept up to date.
+	// The returned error only concerns the initial rendering, later errors are reported as events.
+	Render(ctx context.Context, templateText, outPath string, perms os.FileMode, opts ...RenderOption) error
+
+	// GoogleTokenSource returns an OAuth2 token source backed by the token endpoint of a roleset, static account or
+	// impersonated account in the GCP secrets engine, e.g. "gcp/roleset/my-roleset/token". The token source caches
+	// the access token and issues a new one from Vault when it is about to expire, so it can be given directly to
+	// the Google client libraries, e.g. with option.WithTokenSource.
+	GoogleTokenSource(ctx context.Context, path string) (oauth2.TokenSource, error)
+
+	// GoogleCredentialsJSON returns the service account key issued by the key endpoint of a roleset or static account
+	// in the GCP secrets engine, e.g. "gcp/static-account/my-account/key", as the JSON of a credentials file. A new
+	// key is issued when the lease of the current one expires.
+	GoogleCredentialsJSON(ctx context.Context, path string) (GoogleCredentialsFunc, error)
+
+	// AWSCredentialsProvider returns an AWS credentials provider backed by the AWS secrets engine, e.g.
+	// "aws/creds/my-role" for IAM user credentials or "aws/sts/my-role" for STS credentials. The provider caches the
+	// credentials and renews their lease a few minutes before it expires, or issues new ones from Vault once the lease
+	// has reached its max TTL, so it can be given directly to the AWS SDK, e.g. as the Credentials of an aws.Config.
+	// The lease of the replaced credentials is revoked a minute later, and the lease of the current ones is revoked by
+	// Close if WithRevokeOnClose is set.
+	AWSCredentialsProvider(ctx context.Context, path string) (aws.CredentialsProvider, error)
+
+	// AzureCredential returns a dynamic service principal issued by the Azure secrets engine, e.g.
+	// "azure/creds/my-role", for the given Azure AD tenant. The returned credential provides the client ID and
+	// secret, which are renewed as the lease expires, and implements azcore.TokenCredential so it can be given
+	// directly to the Azure SDK clients. Since new service principals take a while to replicate in Azure AD, token
+	// requests with fresh credentials are retried for a while, see WithAzurePropagationTimeout and WithAzureProbe.
+	AzureCredential(ctx context.Context, path, tenantID string, opts ...AzureOption) (*AzureCredential, error)
+
+	// SSHSigner has the public key of the given signer signed by the SSH secrets engine, e.g. "ssh/sign/my-role", and
+	// returns a signer that presents the resulting user certificate. The certificate is re-signed before it expires,
+	// so the returned signer can be used with golang.org/x/crypto/ssh for the lifetime of the service, e.g. with
+	// ssh.PublicKeys.
+	SSHSigner(ctx context.Context, path string, signer ssh.Signer, opts ...SSHOption) (ssh.Signer, error)
+
+	// Wrap stores data in Vault's cubbyhole and returns a single-use response-wrapping token that is valid for the given
+	// TTL, which must be at least a second. This is useful for handing secrets to other processes, which can retrieve
+	// the data with Unwrap, or with "vault unwrap".
+	Wrap(ctx context.Context, data map[string]any, ttl time.Duration) (string, error)
+
+	// Unwrap returns the data wrapped by the given response-wrapping token. The token can only be unwrapped once.
+	Unwrap(ctx context.Context, wrappingToken string) (map[string]any, error)
+
+	// EventStats returns the number of errors reported by the background jobs, and how many of them were dropped
+	// because the error handler or the error channel did not keep up.
+	EventStats() EventStats
+
+	// Close stops the background jobs that keep the token and the secrets up to date, and removes the files written
+	// by the SecretsManager, such as the Google credentials. Secrets that have already been fetched are still
+	// available after Close, but they are no longer renewed. With WithRevokeOnClose, their leases are also revoked.
+	Close() error
 }
 
 // EvergreenSecretsFunc is a function that returns a map of secrets. The point is that the returned function will always
@@ -24,11 +102,19 @@ type EvergreenSecretsFunc func() map[string]any
 
 // secret contains all data and metadata from a Vault secret
 type secret struct {
-	RequestID     string                            `json:"request_id"`
-	LeaseID       string                            `json:"lease_id"`
-	Renewable     bool                              `json:"renewable"`
-	LeaseDuration int                               `json:"lease_duration"`
-	Data          map[string]map[string]interface{} `json:"data"`
+	RequestID     string                 `json:"request_id"`
+	LeaseID       string                 `json:"lease_id"`
+	Renewable     bool                   `json:"renewable"`
+	LeaseDuration int                    `json:"lease_duration"`
+	Data          map[string]interface{} `json:"data"`
+	WrapInfo      *wrapInfo              `json:"wrap_info"`
+}
+
+// wrapInfo describes a response-wrapping token returned by Vault.
+type wrapInfo struct {
+	Token        string `json:"token"`
+	TTL          int    `json:"ttl"`
+	CreationPath string `json:"creation_path"`
 }
 
 func (s *secret) requestID() string {
@@ -47,10 +133,26 @@ func (s *secret) leaseDuration() int {
 	return s.LeaseDuration
 }
 
+// data returns the data of the secret. For KV version 2 secrets the data is nested under the key "data" next to the
+// metadata, while other secrets engines (KV version 1, GCP, AWS etc.) return the data directly.
 func (s *secret) data() map[string]interface{} {
-	return s.Data["data"]
+	if d, ok := s.Data["data"].(map[string]interface{}); ok && s.isKV2() {
+		return d
+	}
+	return s.Data
 }
 
 func (s *secret) metadata() map[string]interface{} {
-	return s.Data["metadata"]
+	if m, ok := s.Data["metadata"].(map[string]interface{}); ok && s.isKV2() {
+		return m
+	}
+	return nil
+}
+
+// isKV2 reports whether the secret was read from KV version 2, which always returns the data together with its
+// metadata. Other secrets engines may well return a field named data, so its shape alone says nothing.
+func (s *secret) isKV2() bool {
+	_, hasData := s.Data["data"].(map[string]interface{})
+	_, hasMetadata := s.Data["metadata"].(map[string]interface{})
+	return hasData && hasMetadata
 }
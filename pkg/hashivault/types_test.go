@@ -0,0 +1,46 @@
+package hashivault
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_secret_data(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         map[string]any
+		wantData     map[string]any
+		wantMetadata map[string]any
+	}{
+		{
+			name: "kv version 2",
+			data: map[string]any{
+				"data":     map[string]any{"password": "s3cr3t"},
+				"metadata": map[string]any{"version": float64(2)},
+			},
+			wantData:     map[string]any{"password": "s3cr3t"},
+			wantMetadata: map[string]any{"version": float64(2)},
+		},
+		{
+			name:     "kv version 1 with a nested data field",
+			data:     map[string]any{"data": map[string]any{"password": "s3cr3t"}},
+			wantData: map[string]any{"data": map[string]any{"password": "s3cr3t"}},
+		},
+		{
+			name:     "database engine",
+			data:     map[string]any{"username": "v-app-1", "password": "s3cr3t"},
+			wantData: map[string]any{"username": "v-app-1", "password": "s3cr3t"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &secret{Data: tt.data}
+			if got := s.data(); !reflect.DeepEqual(got, tt.wantData) {
+				t.Errorf("unexpected data, got: %v", got)
+			}
+			if got := s.metadata(); !reflect.DeepEqual(got, tt.wantMetadata) {
+				t.Errorf("unexpected metadata, got: %v", got)
+			}
+		})
+	}
+}
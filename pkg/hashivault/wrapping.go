@@ -0,0 +1,68 @@
+package hashivault
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+func (m *manager) Wrap(ctx context.Context, data map[string]any, ttl time.Duration) (string, error) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(
+		ctx,
+		"hashivault.Wrap",
+		trace.WithAttributes(attribute.String("ttl", ttl.String())))
+	defer span.End()
+
+	if ttl < time.Second {
+		err := fmt.Errorf("wrapping ttl must be at least one second, got %s", ttl)
+		traceError(span, err, m.l)
+		return "", err
+	}
+
+	req, err := writeReq(makeURL(m.vaultAddress, "sys/wrapping/wrap"), m.tokenGetter(), data)
+	if err != nil {
+		traceError(span, err, m.l)
+		return "", err
+	}
+	req.Header.Set("X-Vault-Wrap-TTL", ttl.String())
+
+	sec, err := send(req.WithContext(spanCtx), "sys/wrapping/wrap", m.client)
+	if err != nil {
+		traceError(span, err, m.l)
+		return "", err
+	}
+	if sec.WrapInfo == nil || sec.WrapInfo.Token == "" {
+		err := fmt.Errorf("no wrapping token in response from sys/wrapping/wrap")
+		traceError(span, err, m.l)
+		return "", err
+	}
+
+	m.l.Printf("wrapped %d keys with ttl %d seconds", len(data), sec.WrapInfo.TTL)
+	return sec.WrapInfo.Token, nil
+}
+
+func (m *manager) Unwrap(ctx context.Context, wrappingToken string) (map[string]any, error) {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+	spanCtx, span := tracer.Start(ctx, "hashivault.Unwrap")
+	defer span.End()
+
+	// The wrapping token authenticates the request, so the token of the manager is not needed.
+	req, err := writeReq(makeURL(m.vaultAddress, "sys/wrapping/unwrap"), wrappingToken, map[string]any{})
+	if err != nil {
+		traceError(span, err, m.l)
+		return nil, err
+	}
+
+	sec, err := send(req.WithContext(spanCtx), "sys/wrapping/unwrap", m.client)
+	if err != nil {
+		traceError(span, err, m.l)
+		return nil, err
+	}
+
+	m.l.Printf("unwrapped %d keys", len(sec.Data))
+	return sec.Data, nil
+}
@@ -0,0 +1,79 @@
+package hashivault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_manager_Wrap(t *testing.T) {
+	mux := &sync.Mutex{}
+	cubbyhole := map[string]map[string]any{}
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+
+		switch r.URL.Path {
+		case "/v1/sys/wrapping/wrap":
+			if r.Header.Get("X-Vault-Token") != "token" || r.Header.Get("X-Vault-Wrap-TTL") != "5m0s" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			var data map[string]any
+			NoErr(t, json.NewDecoder(r.Body).Decode(&data))
+			cubbyhole["s.wrapped"] = data
+			json.NewEncoder(w).Encode(map[string]any{
+				"wrap_info": map[string]any{"token": "s.wrapped", "ttl": 300, "creation_path": "sys/wrapping/wrap"},
+			})
+		case "/v1/sys/wrapping/unwrap":
+			data, ok := cubbyhole[r.Header.Get("X-Vault-Token")]
+			if !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]any{"errors": []string{"wrapping token is not valid or does not exist"}})
+				return
+			}
+			delete(cubbyhole, r.Header.Get("X-Vault-Token"))
+			json.NewEncoder(w).Encode(map[string]any{"data": data})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	data := map[string]any{"password": "secret"}
+	token, err := m.Wrap(context.Background(), data, 5*time.Minute)
+	NoErr(t, err)
+	if token != "s.wrapped" {
+		t.Errorf("unexpected wrapping token, got: %s", token)
+	}
+
+	got, err := m.Unwrap(context.Background(), token)
+	NoErr(t, err)
+	if !reflect.DeepEqual(got, data) {
+		t.Errorf("unexpected unwrapped data, got: %v", got)
+	}
+
+	if _, err := m.Unwrap(context.Background(), token); err == nil {
+		t.Error("expected error when unwrapping a token twice")
+	}
+
+	if _, err := m.Wrap(context.Background(), data, 0); err == nil {
+		t.Error("expected error without a ttl")
+	}
+	if _, err := m.Wrap(context.Background(), data, 500*time.Millisecond); err == nil {
+		t.Error("expected error with a ttl shorter than a second")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := m.Wrap(ctx, data, 5*time.Minute); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the error of ctx when wrapping, got: %v", err)
+	}
+	if _, err := m.Unwrap(ctx, token); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the error of ctx when unwrapping, got: %v", err)
+	}
+}